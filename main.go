@@ -22,5 +22,5 @@ func main() {
 	}).Info("Starting Carlos the Curious")
 
 	slackbot.SetupDatabase(conf.DatabaseURL, conf.Debug)
-	slackbot.Run(conf.Origin, conf.SlackAPIToken, conf.Workers)
+	slackbot.Run(conf)
 }
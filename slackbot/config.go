@@ -26,25 +26,49 @@ type Config struct {
 
 	// Workers is the number of goroutines to spin up for processing the message queue
 	Workers int `json:"workers"`
+
+	// SigningSecret is used to verify requests Slack sends us such as button clicks
+	SigningSecret string `json:"signing_secret"`
+
+	// Transport is how messages from Slack reach us, "rtm" (the default), "events" for the Events API or
+	// "socket" for Socket Mode. "mattermost" runs on a Mattermost server instead
+	Transport string `json:"transport"`
+
+	// AppToken is the app level token (xapp-...) Socket Mode connects with
+	AppToken string `json:"app_token"`
+
+	// MattermostURL and MattermostToken are the server and bot access token used by the mattermost transport
+	MattermostURL   string `json:"mattermost_url"`
+	MattermostToken string `json:"mattermost_token"`
 }
 
 var (
-	token       = flag.String("token", "", "Slack authentication token")
-	databaseURL = flag.String("database_url", "", "Name of the database we are connecting to")
-	origin      = flag.String("origin", "https://api.slack.com", "Slack origin url")
-	debug       = flag.Bool("debug", false, "Enable debug mode")
-	workers     = flag.Int("workers", 4, "Configure the number of message workers")
+	token         = flag.String("token", "", "Slack authentication token")
+	databaseURL   = flag.String("database_url", "", "Name of the database we are connecting to")
+	origin        = flag.String("origin", "https://api.slack.com", "Slack origin url")
+	debug         = flag.Bool("debug", false, "Enable debug mode")
+	workers       = flag.Int("workers", 4, "Configure the number of message workers")
+	signingSecret = flag.String("signing_secret", "", "Slack signing secret used to verify interactive requests")
+	transport     = flag.String("transport", "", "How to receive messages from Slack, rtm, events or socket (defaults to rtm), or mattermost")
+	appToken      = flag.String("app_token", "", "Slack app level token used to connect with Socket Mode")
+	mattermostURL = flag.String("mattermost_url", "", "Mattermost server url used by the mattermost transport")
+	mmToken       = flag.String("mattermost_token", "", "Mattermost bot access token used by the mattermost transport")
 )
 
 // LoadFromFlags loads all global config from CLI flags
 func LoadFromFlags() (*Config, error) {
 	flag.Parse()
 	return &Config{
-		SlackAPIToken: *token,
-		DatabaseURL:   *databaseURL,
-		Origin:        *origin,
-		Debug:         *debug,
-		Workers:       *workers,
+		SlackAPIToken:   *token,
+		DatabaseURL:     *databaseURL,
+		Origin:          *origin,
+		Debug:           *debug,
+		Workers:         *workers,
+		SigningSecret:   *signingSecret,
+		Transport:       *transport,
+		AppToken:        *appToken,
+		MattermostURL:   *mattermostURL,
+		MattermostToken: *mmToken,
 	}, nil
 }
 
@@ -106,5 +130,39 @@ func LoadConfig() (*Config, error) {
 	} else {
 		config.Workers = config_flags.Workers
 	}
+
+	if config_flags.SigningSecret == "" {
+		config.SigningSecret = config_file.SigningSecret
+	} else {
+		config.SigningSecret = config_flags.SigningSecret
+	}
+
+	if config_flags.Transport == "" {
+		config.Transport = config_file.Transport
+	} else {
+		config.Transport = config_flags.Transport
+	}
+
+	if config_flags.AppToken == "" {
+		config.AppToken = config_file.AppToken
+	} else {
+		config.AppToken = config_flags.AppToken
+	}
+
+	if config_flags.MattermostURL == "" {
+		config.MattermostURL = config_file.MattermostURL
+	} else {
+		config.MattermostURL = config_flags.MattermostURL
+	}
+
+	if config_flags.MattermostToken == "" {
+		config.MattermostToken = config_file.MattermostToken
+	} else {
+		config.MattermostToken = config_flags.MattermostToken
+	}
+
+	if config.Transport == "" {
+		config.Transport = RTMTransport
+	}
 	return &config, nil
 }
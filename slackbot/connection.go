@@ -0,0 +1,202 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// Slack recommends pinging the RTM connection so a connection that died quietly gets noticed
+	pingInterval = 30 * time.Second
+
+	// The connection is treated as dead when Slack hasn't answered a ping for this long
+	pongTimeout = 2 * pingInterval
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 2 * time.Minute
+)
+
+var dialWebsocket = func(url, origin string) (*websocket.Conn, error) {
+	return websocket.Dial(url, "", origin)
+}
+
+// Backoff works out how long to wait between attempts at something that keeps failing. The wait starts at Min
+// and doubles after every attempt until it reaches Max
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	attempts uint
+}
+
+func (backoff *Backoff) Next() time.Duration {
+	delay := backoff.Min << backoff.attempts
+	backoff.attempts++
+
+	// Shifting far enough overflows so anything that went negative is past the max anyway
+	if delay <= 0 || delay > backoff.Max {
+		return backoff.Max
+	}
+	return delay
+}
+
+func (backoff *Backoff) Reset() {
+	backoff.attempts = 0
+}
+
+// rtmConnection is a websocket to Slack which can be swapped for a new one after reconnecting
+type rtmConnection struct {
+	mu           sync.Mutex
+	conn         *websocket.Conn
+	reconnectURL string
+	lastPong     time.Time
+}
+
+func (rtm *rtmConnection) get() *websocket.Conn {
+	if rtm == nil {
+		return nil
+	}
+
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	return rtm.conn
+}
+
+func (rtm *rtmConnection) set(conn *websocket.Conn) {
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	rtm.conn = conn
+	rtm.lastPong = time.Now()
+}
+
+// close drops the websocket. Anything waiting to receive on it gets an error and reconnects
+func (rtm *rtmConnection) close() {
+	if conn := rtm.get(); conn != nil {
+		conn.Close()
+	}
+}
+
+func (rtm *rtmConnection) pong(now time.Time) {
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	rtm.lastPong = now
+}
+
+func (rtm *rtmConnection) sinceLastPong(now time.Time) time.Duration {
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	return now.Sub(rtm.lastPong)
+}
+
+func (rtm *rtmConnection) setReconnectURL(url string) {
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	rtm.reconnectURL = url
+}
+
+// takeReconnectURL hands out the reconnect url once. If it doesn't work we start over with rtm.start
+func (rtm *rtmConnection) takeReconnectURL() string {
+	rtm.mu.Lock()
+	defer rtm.mu.Unlock()
+	url := rtm.reconnectURL
+	rtm.reconnectURL = ""
+	return url
+}
+
+// connectRTM starts an RTM session and opens the websocket for it
+func (slack *SlackTransport) connectRTM() (*Identity, error) {
+	slackResponse, err := slackStart(slack.APIToken)
+	if err != nil {
+		return nil, err
+	}
+
+	websock, err := dialWebsocket(slackResponse.URL, slack.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	if slack.rtm == nil {
+		slack.rtm = &rtmConnection{}
+	}
+	slack.rtm.set(websock)
+	return &Identity{ID: slackResponse.Self.ID, Name: slackResponse.Self.Name}, nil
+}
+
+// reconnect keeps trying until there is a working websocket again. The reconnect url Slack gave us is tried
+// first, after that it is a fresh rtm.start each time
+func (slack *SlackTransport) reconnect() {
+	slack.rtm.close()
+
+	backoff := &Backoff{Min: minReconnectDelay, Max: maxReconnectDelay}
+	for {
+		if url := slack.rtm.takeReconnectURL(); url != "" {
+			websock, err := dialWebsocket(url, slack.Origin)
+			if err == nil {
+				slack.rtm.set(websock)
+				logrus.Info("Reconnected to Slack")
+				return
+			}
+			logrus.Warn("Unable to use the reconnect url: ", err)
+		}
+
+		_, err := slack.connectRTM()
+		if err == nil {
+			logrus.Info("Reconnected to Slack")
+			return
+		}
+
+		delay := backoff.Next()
+		logrus.WithField("retry_in", delay).Warn("Unable to reconnect to Slack: ", err)
+		time.Sleep(delay)
+	}
+}
+
+// isDisconnect tells a dead connection apart from a message we couldn't decode. A message we don't understand
+// has already been read so there is no need to throw away the connection over it
+func isDisconnect(err error) bool {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	}
+	return true
+}
+
+// handleConnectionEvent deals with the RTM events which are about the connection rather than conversation
+func (slack *SlackTransport) handleConnectionEvent(msg *Message, now time.Time) bool {
+	switch msg.Type {
+	case "hello", "pong":
+		slack.rtm.pong(now)
+	case "reconnect_url":
+		slack.rtm.setReconnectURL(msg.URL)
+	case "goodbye":
+		logrus.Info("Slack is closing the connection, reconnecting")
+		slack.reconnect()
+	default:
+		return false
+	}
+	return true
+}
+
+// keepAlive pings Slack every so often. When the pongs stop coming back the connection is closed, which makes
+// the listener reconnect
+func (slack *SlackTransport) keepAlive() {
+	ticker := time.NewTicker(pingInterval)
+	for now := range ticker.C {
+		if slack.rtm.sinceLastPong(now) > pongTimeout {
+			logrus.Warn("Slack stopped answering pings, dropping the connection")
+			slack.rtm.close()
+			continue
+		}
+
+		ping := &Message{ID: atomic.AddUint64(&counter, 1), Type: "ping"}
+		if err := sendOverWebsocket(slack.rtm.get(), ping); err != nil {
+			logrus.Warn("Unable to ping Slack: ", err)
+		}
+	}
+}
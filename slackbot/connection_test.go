@@ -0,0 +1,77 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := &Backoff{Min: time.Second, Max: 10 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, delay := range expected {
+		if next := backoff.Next(); next != delay {
+			t.Error("Expected attempt", i+1, "to wait", delay, "but got", next)
+		}
+	}
+
+	// Enough attempts to overflow the shift still waits the max
+	for i := 0; i < 100; i++ {
+		backoff.Next()
+	}
+	if next := backoff.Next(); next != backoff.Max {
+		t.Error("Expected the wait to stay at the max but got", next)
+	}
+
+	backoff.Reset()
+	if next := backoff.Next(); next != time.Second {
+		t.Error("Expected the wait to start over after a reset but got", next)
+	}
+}
+
+func TestHandleConnectionEvent(t *testing.T) {
+	slack := &SlackTransport{rtm: &rtmConnection{}}
+	now := time.Now()
+
+	var testCases = []struct {
+		Msg             Message
+		ExpectedHandled bool
+	}{
+		{Msg: Message{Type: "hello"}, ExpectedHandled: true},
+		{Msg: Message{Type: "pong"}, ExpectedHandled: true},
+		{Msg: Message{Type: "reconnect_url", URL: "wss://example.com/again"}, ExpectedHandled: true},
+		{Msg: Message{Type: "message", Text: "create response poll"}, ExpectedHandled: false},
+	}
+
+	for _, testCase := range testCases {
+		if handled := slack.handleConnectionEvent(&testCase.Msg, now); handled != testCase.ExpectedHandled {
+			t.Error("Expected", testCase.Msg.Type, "handled to be", testCase.ExpectedHandled)
+		}
+	}
+
+	if since := slack.rtm.sinceLastPong(now); since != 0 {
+		t.Error("Expected the pong to be recorded but it was", since, "ago")
+	}
+
+	if url := slack.rtm.takeReconnectURL(); url != "wss://example.com/again" {
+		t.Error("Expected the reconnect url to be kept got", url)
+	}
+
+	if url := slack.rtm.takeReconnectURL(); url != "" {
+		t.Error("Expected the reconnect url to only be handed out once got", url)
+	}
+}
+
+func TestIsDisconnect(t *testing.T) {
+	unreadable := json.Unmarshal([]byte(`{"id": "not a number"}`), &Message{})
+
+	if !isDisconnect(io.EOF) {
+		t.Error("Expected the connection closing to be a disconnect")
+	}
+
+	if isDisconnect(unreadable) {
+		t.Error("Expected a message we can't read to keep the connection")
+	}
+}
@@ -4,71 +4,256 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 )
 
 var (
-	slackIDRegex            = regexp.MustCompile("<@([a-zA-Z0-9]+)>")
-	slackPublicGroupIDRegex = regexp.MustCompile("<#([a-zA-Z0-9]+)[|]{1}[a-zA-Z0-9-]+>")
-	stageLookup             = map[string]Stage{
-		"initial":       getQuestion,
-		"getAnswers":    getAnswers,
-		"getRecipients": getRecipients,
-		"sendPoll":      sendPoll,
+	slackIDRegex           = regexp.MustCompile("<@([a-zA-Z0-9]+)>")
+	slackChannelIDRegex    = regexp.MustCompile("<#([a-zA-Z0-9]+)(?:[|]([^>]*))?>")
+	slackUserGroupIDRegex  = regexp.MustCompile(`<!subteam\^([a-zA-Z0-9]+)(?:[|]([^>]*))?>`)
+	slackWholeChannelRegex = regexp.MustCompile("<!(here|channel|everyone)(?:[|][^>]*)?>")
+	exceptRegex            = regexp.MustCompile(`(?i)\s+except\s+`)
+	channelNameRegex       = regexp.MustCompile(`(?:^|[\s,])#([a-z0-9_-]+)`)
+	createSurveyPattern    = "^[cC]reate survey (.+)$"
+	createSurveyRegex      = regexp.MustCompile(createSurveyPattern)
+	fromTemplatePattern    = "^[cC]reate poll from template (.+)$"
+	fromTemplateRegex      = regexp.MustCompile(fromTemplatePattern)
+	questionPrompt         = "What was the question you wanted to ask?"
+	answersPrompt          = "What are the possible responses (comma separated)?"
+	recipientsPrompt       = "Who should we send this to?"
+	postChannelPrompt      = "Which channel should the poll be posted in? Everyone in the channel can answer, leave people out with _except_"
+	closeTimePrompt        = "When should the poll close? You can say something like `2h`, `3d`, `2017-01-31 17:00` or `never`"
+	stageLookup            = map[string]Stage{
+		StageGetQuestion:   getQuestion,
+		StageGetAnswers:    getAnswers,
+		StageGetScale:      getScale,
+		StageGetRecipients: getRecipients,
+		StageGetCloseTime:  getCloseTime,
+		StageSendPoll:      sendPoll,
 	}
 
 	registeredCommands = map[string]HandlerFunc{
-		"^[sS]how poll (.*)$":                     showPoll,
-		"^[cC]reate ([a-zA-Z]+) poll$":            createPoll,
-		"^[cC]ancel poll ([a-zA-Z-0-9-_]+)$":      cancelPoll,
-		"^[aA]nswer poll ([a-zA-Z-0-9-_]+) (.*$)": answerPoll,
-		"^[lL]ist active polls$":                  activePolls,
-		"^[hH]elp":                                usage,
+		"^[sS]how poll (.*)$":                                             showPoll,
+		"^[cC]reate ([a-zA-Z]+) poll$":                                    createPoll,
+		"^[cC]reate ([a-zA-Z ]+) ([a-zA-Z]+) poll$":                       createPollWithOptions,
+		"^[cC]reate ([a-zA-Z ]*?)([a-zA-Z]+) poll reminding every (.+)$":  createPollWithReminders,
+		"^[cC]ancel poll ([a-zA-Z-0-9-_]+)$":                              cancelPoll,
+		"^[aA]nswer poll ([a-zA-Z-0-9-_]+) (.*$)":                         answerPoll,
+		"^[aA]rchive poll ([a-zA-Z-0-9-_]+)$":                             archivePoll,
+		"^[lL]ist active polls$":                                          activePolls,
+		"^[lL]ist archived polls$":                                        archivedPolls,
+		"^[sS]chedule poll ([a-zA-Z-0-9-_]+) (.+)$":                       schedulePoll,
+		"^[uU]nschedule poll ([a-zA-Z-0-9-_]+)$":                          unschedulePoll,
+		"^[rR]emind poll ([a-zA-Z-0-9-_]+)$":                              remindPoll,
+		"^[rR]emind poll ([a-zA-Z-0-9-_]+) every (.+)$":                   setPollReminders,
+		createSurveyPattern:                                               createSurvey,
+		"^[aA]dd ([a-zA-Z]+) question to survey ([a-zA-Z-0-9-_]+)$":       addSurveyQuestion,
+		"^[sS]end survey ([a-zA-Z-0-9-_]+) to (.+)$":                      sendSurvey,
+		"^[sS]how survey ([a-zA-Z-0-9-_]+)$":                              showSurvey,
+		"^[rR]esume survey$":                                              resumeSurvey,
+		"^[sS]ave poll ([a-zA-Z-0-9-_]+) as template (.+)$":               savePollTemplate,
+		"^[lL]ist templates$":                                             listTemplates,
+		fromTemplatePattern:                                               createPollFromTemplate,
+		"^[aA]dd follow up to poll ([a-zA-Z-0-9-_]+) when (.+) ask (.+)$": addFollowUp,
+		"^[aA]nswer follow up ([a-zA-Z-0-9-_]+) (.+)$":                    answerFollowUp,
+		"^[lL]ist drafts$":                                                listDrafts,
+		"^[rR]esume poll ([a-zA-Z-0-9-_]+)$":                              resumePoll,
+		"^[eE]dit question$":                                              editQuestion,
+		"^[eE]dit answers$":                                               editAnswers,
+		"^[eE]dit recipients$":                                            editRecipients,
+		"^([gG]o )?[bB]ack$":                                              goBack,
+		"^[hH]elp":                                                        usage,
 	}
 )
 
-func findChannels(robot *Robot, msg Message) []Recipient {
+// recipientFor accepts anyone the chat platform told us about as well as anything shaped like a Slack user id,
+// which covers platforms like Mattermost whose ids look nothing like Slack's
+func (robot *Robot) recipientFor(id string) (*Recipient, error) {
+	if _, ok := robot.Users[id]; ok {
+		return &Recipient{SlackID: id}, nil
+	}
+	return NewRecipient(id)
+}
+
+// membersAsRecipients turns the members of a channel, group or user group into recipients
+func membersAsRecipients(robot *Robot, members []string) []Recipient {
+	recipients := []Recipient{}
+	for _, member := range members {
+		recipient, err := robot.recipientFor(member)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		recipients = append(recipients, *recipient)
+	}
+	return recipients
+}
+
+// findChannelMembers looks for the members of a public channel or private group the bot knows about
+func findChannelMembers(robot *Robot, id string) ([]string, bool) {
+	if channel, ok := robot.Channels[id]; ok {
+		return channel.Members, true
+	}
+
+	if group, ok := robot.Groups[id]; ok {
+		return group.Members, true
+	}
+	return nil, false
+}
+
+// findChannels resolves channel and private group mentions such as <#C024BE91L|general>
+func findChannels(robot *Robot, msg Message) ([]Recipient, []string) {
 	recipients := []Recipient{}
+	unresolved := []string{}
 
-	for _, match := range slackPublicGroupIDRegex.FindAllStringSubmatch(msg.Text, -1) {
-		id := match[1]
-		channel, ok := robot.Channels[id]
+	for _, match := range slackChannelIDRegex.FindAllStringSubmatch(msg.Text, -1) {
+		members, ok := findChannelMembers(robot, match[1])
 		if !ok {
-			logrus.Error("Unable to find channel with id: ", id)
-		} else {
-			for _, r := range channel.Members {
-				recipient, err := NewRecipient(r)
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-				recipients = append(recipients, *recipient)
-			}
+			unresolved = append(unresolved, "#"+firstNonEmpty(match[2], match[1]))
+			continue
 		}
+		recipients = append(recipients, membersAsRecipients(robot, members)...)
 	}
-	return recipients
+	return recipients, unresolved
+}
+
+// findChannelNames resolves channels typed as plain text like #general rather than picked from the mention list
+func findChannelNames(robot *Robot, msg Message) ([]Recipient, []string) {
+	recipients := []Recipient{}
+	unresolved := []string{}
+
+	for _, match := range channelNameRegex.FindAllStringSubmatch(msg.Text, -1) {
+		id, ok := findChannelIDByName(robot, match[1])
+		if !ok {
+			unresolved = append(unresolved, "#"+match[1])
+			continue
+		}
+		members, _ := findChannelMembers(robot, id)
+		recipients = append(recipients, membersAsRecipients(robot, members)...)
+	}
+	return recipients, unresolved
+}
+
+func findChannelIDByName(robot *Robot, name string) (string, bool) {
+	for id, channel := range robot.Channels {
+		if channel.Name == name {
+			return id, true
+		}
+	}
+
+	for id, group := range robot.Groups {
+		if group.Name == name {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// findPostChannel is the channel a channel poll gets posted in. The creator has to mention exactly one
+// channel, anyone else mentioned only changes who can answer
+func findPostChannel(robot *Robot, text string) (string, bool) {
+	channels := make(map[string]bool)
+	for _, match := range slackChannelIDRegex.FindAllStringSubmatch(text, -1) {
+		channels[match[1]] = true
+	}
+
+	for _, match := range channelNameRegex.FindAllStringSubmatch(text, -1) {
+		if id, ok := findChannelIDByName(robot, match[1]); ok {
+			channels[id] = true
+		}
+	}
+
+	if len(channels) != 1 {
+		return "", false
+	}
+
+	for id := range channels {
+		return id, true
+	}
+	return "", false
+}
+
+// findUserGroups resolves user group mentions such as <!subteam^SAZ94GDB8|@engineering>
+func findUserGroups(robot *Robot, msg Message) ([]Recipient, []string) {
+	recipients := []Recipient{}
+	unresolved := []string{}
+
+	for _, match := range slackUserGroupIDRegex.FindAllStringSubmatch(msg.Text, -1) {
+		userGroup, ok := robot.UserGroups[match[1]]
+		if !ok {
+			unresolved = append(unresolved, firstNonEmpty(match[2], match[1]))
+			continue
+		}
+		recipients = append(recipients, membersAsRecipients(robot, userGroup.Users)...)
+	}
+	return recipients, unresolved
+}
+
+// findWholeChannel resolves @here, @channel and @everyone to everyone in the channel the message was sent in.
+// Carlos doesn't know who is online so @here is everyone as well
+func findWholeChannel(robot *Robot, msg Message) ([]Recipient, []string) {
+	recipients := []Recipient{}
+	unresolved := []string{}
+
+	for _, match := range slackWholeChannelRegex.FindAllStringSubmatch(msg.Text, -1) {
+		members, ok := findChannelMembers(robot, msg.Channel)
+		if !ok {
+			unresolved = append(unresolved, "@"+match[1])
+			continue
+		}
+		recipients = append(recipients, membersAsRecipients(robot, members)...)
+	}
+	return recipients, unresolved
 }
 
-func findUsers(msg Message) []Recipient {
+func findUsers(robot *Robot, msg Message) ([]Recipient, []string) {
 	recipients := []Recipient{}
+	unresolved := []string{}
+
 	for _, match := range slackIDRegex.FindAllStringSubmatch(msg.Text, -1) {
-		recipient, err := NewRecipient(match[1])
+		recipient, err := robot.recipientFor(match[1])
 		if err != nil {
-			logrus.Error(err)
+			unresolved = append(unresolved, match[0])
 			continue
 		}
 
 		recipients = append(recipients, *recipient)
 	}
-	return recipients
+	return recipients, unresolved
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
-func parseRecpientsText(robot *Robot, msg Message) []Recipient {
+// parseRecpientsText finds everyone mentioned in the message. Mentions which could not be turned into people,
+// like a channel Carlos isn't in, are returned so the creator can be told about them
+func parseRecpientsText(robot *Robot, msg Message) ([]Recipient, []string) {
 	recipientList := []Recipient{}
-	recipientList = append(recipientList, findChannels(robot, msg)...)
-	recipientList = append(recipientList, findUsers(msg)...)
+	unresolved := []string{}
+
+	finders := []func(*Robot, Message) ([]Recipient, []string){
+		findChannels,
+		findChannelNames,
+		findUserGroups,
+		findWholeChannel,
+		findUsers,
+	}
+
+	for _, find := range finders {
+		found, missing := find(robot, msg)
+		recipientList = append(recipientList, found...)
+		unresolved = append(unresolved, missing...)
+	}
 
 	recipients := make(map[string]Recipient)
 	for _, v := range recipientList {
@@ -80,7 +265,7 @@ func parseRecpientsText(robot *Robot, msg Message) []Recipient {
 	for _, v := range recipients {
 		recipientList = append(recipientList, v)
 	}
-	return recipientList
+	return recipientList, unresolved
 }
 
 func usage(robot *Robot, msg *Message, captureGroups []string) error {
@@ -94,24 +279,101 @@ Carlos the Curious at your service! Create and gather feedback to simple survey
 ask you follow up questions to build the survey don't worry you can cancel at
 any time. If you choose _feedback_ the answer can be freeform, if _response_ the answers show be one of the supplied responses.
 
-*'cancel poll {poll_uuid}'* - Cancel a currently active or inprogress poll.
+When Carlos asks who to send the poll to mention people, channels, private groups, user groups or use @channel
+to send it to everyone in the channel you are in. Leave people out with _except_ e.g _#engineering except @alice @bob_.
+Bots, deactivated accounts and you are left out of channels automatically. Carlos lets you know about anything it can't find.
+
+*'create multiple poll'* - Same as a response poll except recipients can pick all the answers that apply.
+
+*'create scale poll'* - Ask for a rating such as 1 to 5. Carlos will ask for the scale and the results show the mean, median,
+standard deviation and how the ratings are spread out.
+
+*'create anonymous {feedback|response|multiple|scale} poll'* - Same as above except nobody, including you, can see who gave which answer.
+
+*'create open {feedback|response|multiple|scale} poll'* - Normally only the recipients can answer a poll. Open polls take answers from anyone
+who knows the poll id, handy for asking a whole channel.
+
+*'create channel {feedback|response|multiple|scale} poll'* - Post the poll once in a channel instead of sending it to everyone
+as a direct message. People answer with the buttons or by replying in the thread and the results on the message update as they do.
+
+*'list drafts'* - List the polls you are part way through creating in this channel. You can have as many on the go as you like.
+
+*'resume poll {poll_uuid}'* - Carry on creating one of your drafts. Carlos picks up where you left off with it.
+
+*'edit {question|answers|recipients}'* - Change part of the poll you are creating, even after seeing the preview. Carlos
+takes you back to where you were once it is changed.
+
+*'back'* - Go back a step while creating a poll to change your last answer
+
+*'cancel poll {poll_uuid}'* - Cancel one of your active or inprogress polls. It stops taking answers and any schedule for it
+is removed.
+
+*'archive poll {poll_uuid}'* - Tidy away one of your active or closed polls once you are done with it. The results are kept.
 
 *'answer poll {poll_uuid} {answer}'* - When Carlos sends you a direct message you can
 answer the poll with the above command. Everything after the poll_name can be free
-text.
+text. Answering again changes your answer.
 
-*'show poll{poll_uuid}'* - Display the results for the mentioned poll
+*'show poll{poll_uuid}'* - Display the results for the mentioned poll. Polls can be given a close time while they
+are being created, once it passes the poll stops taking answers and the final results are posted back to you.
 
 *'list active polls'* - List your active polls
 
+*'list archived polls'* - List the polls you have archived so you can still look at their results
+
+*'schedule poll {poll_uuid} {cron expression}'* - Send a fresh copy of an active poll on a schedule. The schedule
+is a standard cron expression in server time e.g. _0 9 * * MON_ for every Monday at 9am. Copies keep going out
+after the poll closes until it is unscheduled or archived.
+
+*'unschedule poll {poll_uuid}'* - Stop sending copies of the poll
+
+*'remind poll {poll_uuid}'* - Nudge everyone who has not answered your active poll yet
+
+*'remind poll {poll_uuid} every {interval}'* - Automatically nudge everyone who has not answered e.g. _every 1d_.
+Recipients get at most a few reminders, including the ones you ask for. Use _every never_ to turn reminders off.
+Turn them on while creating a poll with _create response poll reminding every 1d_.
+
+*'create survey {title}'* - Start a survey made up of several questions. Carlos walks each recipient through the questions
+one at a time and remembers where they got up to.
+
+*'add {feedback|response|multiple|scale} question to survey {survey_uuid}'* - Add a question to the end of your survey. Carlos
+asks for the question the same way as for a poll.
+
+*'send survey {survey_uuid} to {recipients}'* - Send the survey to people or channels. Recipients can also answer
+each question by just replying in the direct message.
+
+*'resume survey'* - Pick up the survey you are part way through
+
+*'show survey {survey_uuid}'* - Display the results for every question in the survey
+
+*'add follow up to poll {poll_uuid} when {answer} ask {question}'* - Ask anyone who gives the answer to your response poll
+an extra free text question straight away.
+
+*'answer follow up {poll_uuid} {answer}'* - Answer a follow up question. You can also just reply in the direct message.
+
+*'save poll {poll_uuid} as template {name}'* - Remember the question, answers and recipients of your active or closed
+poll so you can send it again
+
+*'list templates'* - List your saved templates
+
+*'create poll from template {name}'* - Start a new poll filled in from the template. Carlos jumps straight to the preview.
+
 *'help'* - Display the help but you already knew that
 `
 	return robot.SendMessage(msg.Channel, usage)
 }
 
 func activePolls(robot *Robot, msg *Message, captures []string) (err error) {
+	return listPolls(robot, msg, StageActive)
+}
+
+func archivedPolls(robot *Robot, msg *Message, captures []string) (err error) {
+	return listPolls(robot, msg, StageArchived)
+}
+
+func listPolls(robot *Robot, msg *Message, stage string) error {
 	polls := []*Poll{}
-	GetDB().Where("creator = ? AND channel = ? AND stage = ?", msg.User, msg.Channel, "active").Find(&polls)
+	GetDB().Where("creator = ? AND channel = ? AND stage = ?", msg.User, msg.Channel, stage).Find(&polls)
 
 	var result bytes.Buffer
 	for k, v := range polls {
@@ -123,28 +385,75 @@ func activePolls(robot *Robot, msg *Message, captures []string) (err error) {
 	}
 
 	if len(polls) == 0 {
-		robot.SendMessage(msg.Channel, "You have no active polls")
+		robot.SendMessage(msg.Channel, fmt.Sprintf("You have no %s polls", stage))
 		return nil
 	}
 
-	robot.PostMessage(msg.Channel, "Here are the list of active polls:", attachment)
+	robot.PostMessage(msg.Channel, fmt.Sprintf("Here are the list of %s polls:", stage), attachment)
 	return nil
 }
 
 func createPoll(robot *Robot, msg *Message, captureGroups []string) error {
-	existing, _ := FindFirstInactivePollByMessage(msg)
-	if existing.ID != 0 {
-		robot.SendMessage(msg.Channel, fmt.Sprintf("There is already a poll being created. Cancel the poll with: 'cancel poll %s'", existing.UUID))
-		return ErrExistingInactivePoll
+	return startPoll(robot, msg, captureGroups[1], nil, 0)
+}
+
+// createPollWithOptions handles creating polls such as `create anonymous response poll`
+func createPollWithOptions(robot *Robot, msg *Message, captureGroups []string) error {
+	// `create survey team poll` or `create poll from template weekly poll` look like a poll with options as well
+	if match := createSurveyRegex.FindStringSubmatch(msg.Text); match != nil {
+		return createSurvey(robot, msg, match)
 	}
 
-	kind := captureGroups[1]
-	if strings.Compare(kind, ResponsePoll) != 0 && strings.Compare(kind, FeedbackPoll) != 0 {
-		robot.SendMessage(msg.Channel, fmt.Sprintf("Poll must be of type response or feedback cannot be %s", kind))
+	if match := fromTemplateRegex.FindStringSubmatch(msg.Text); match != nil {
+		return createPollFromTemplate(robot, msg, match)
+	}
+	return startPoll(robot, msg, captureGroups[2], strings.Fields(captureGroups[1]), 0)
+}
+
+// createPollWithReminders handles `create response poll reminding every 1d` so reminders don't have to be
+// turned on once the poll has gone out
+func createPollWithReminders(robot *Robot, msg *Message, captureGroups []string) error {
+	every, err := parseDuration(captureGroups[3])
+	if err != nil || every < minReminderEvery {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Reminders need an interval of at least %s like `1d` or `12h`", minReminderEvery))
+	}
+	return startPoll(robot, msg, captureGroups[2], strings.Fields(captureGroups[1]), every)
+}
+
+func startPoll(robot *Robot, msg *Message, kind string, options []string, remindEvery time.Duration) error {
+	if !validPollKind(kind) {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Poll must be of type response, multiple, scale or feedback cannot be %s", kind))
 		return ErrInvalidPollType
 	}
 
 	poll := NewPoll(kind, msg.User, msg.Channel)
+	poll.RemindEvery = remindEvery
+	for _, option := range options {
+		switch strings.ToLower(option) {
+		case "anonymous":
+			poll.Anonymous = true
+		case "open":
+			poll.Open = true
+		case "channel":
+			poll.Delivery = ChannelDelivery
+		default:
+			robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry I don't know how to make a poll %s", option))
+			return ErrInvalidPollOption
+		}
+	}
+
+	// We can only stop people answering an anonymous poll twice by remembering which recipients have answered
+	if poll.Anonymous && poll.Open {
+		robot.SendMessage(msg.Channel, "Sorry, anonymous polls can't be open. Only the recipients can answer them")
+		return ErrAnonymousOpenPoll
+	}
+
+	// Threaded replies to a channel poll are there for everyone to see
+	if poll.Anonymous && poll.postsInChannel() {
+		robot.SendMessage(msg.Channel, "Sorry, anonymous polls can't be posted in a channel since everyone can see the threaded replies")
+		return ErrAnonymousChannelPoll
+	}
+
 	if err := poll.Save(); err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			robot.SendMessage(msg.Channel, "Sigh at the moment we need uniquely named polls. Sorry")
@@ -154,29 +463,157 @@ func createPoll(robot *Robot, msg *Message, captureGroups []string) error {
 		return err
 	}
 
-	return robot.SendMessage(msg.Channel, fmt.Sprintf("Creating a %s poll. You can cancel the poll any time with `cancel poll %s`\nWhat was the question you wanted to ask?", kind, poll.UUID))
+	if err := poll.TransitionTo(StageGetQuestion, msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if err := SelectDraft(poll); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	response := fmt.Sprintf("Creating a %s poll. You can cancel the poll any time with `cancel poll %s`\n", kind, poll.UUID)
+	if poll.Anonymous {
+		response += "Answers to this poll are anonymous, nobody including you will be able to see who answered what.\n"
+	}
+	if poll.RemindEvery > 0 {
+		response += fmt.Sprintf("Once it is sent I will remind people who have not answered every %s, up to %d times.\n", poll.RemindEvery, maxReminders)
+	}
+	return robot.SendMessage(msg.Channel, response+questionPrompt)
+}
+
+func validPollKind(kind string) bool {
+	switch kind {
+	case ResponsePoll, FeedbackPoll, MultipleChoicePoll, ScalePoll:
+		return true
+	}
+	return false
 }
 
 func answerPoll(robot *Robot, msg *Message, captureGroups []string) error {
 	pollName := captureGroups[1]
-	poll := &Poll{}
-	if err := GetDB().Where("uuid = ? AND stage = ?", pollName, "active").First(poll).Error; err != nil || poll.ID == 0 {
+	poll, err := FindFirstPublishedPollByUUID(pollName)
+	if err != nil {
 		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a poll with the name %s", pollName))
 		return err
 	}
 
-	if err := poll.AddResponse(msg.User, captureGroups[2]); err != nil {
-		logrus.Error(err)
-		robot.SendMessage(msg.Channel, "We were unable to add your response")
+	answer := captureGroups[2]
+	previous, err := poll.AddResponse(msg.User, answer)
+	if err != nil {
+		robot.SendMessage(msg.Channel, answerErrorMessage(err))
+		return nil
+	}
+
+	if err := refreshPostedPoll(robot, poll); err != nil {
+		logrus.WithField("poll_uuid", poll.UUID).Error("Error updating the posted poll: ", err)
+	}
+
+	if err := robot.SendMessage(msg.Channel, answeredMessage(previous, poll.NormaliseResponse(answer))); err != nil {
+		return err
+	}
+	return followAnswer(robot, msg.Channel, poll, msg.User, previous)
+}
+
+// refreshPostedPoll updates the tallies on a poll posted in a channel. Polls sent as direct messages have
+// nothing to update
+func refreshPostedPoll(robot *Robot, poll *Poll) error {
+	if !poll.postsInChannel() || poll.PostTS == "" {
 		return nil
 	}
+	return robot.UpdateMessage(poll.PostChannel, poll.PostTS, "", poll.SlackChannelAttachment())
+}
+
+// answerThreadReply takes a threaded reply to a channel poll as an answer to it. Problems with the answer are
+// replied to in the thread, a good answer shows up in the tallies
+func answerThreadReply(robot *Robot, msg *Message) (bool, error) {
+	poll, err := FindPollByThread(msg.Channel, msg.ThreadTS)
+	if err != nil {
+		return false, nil
+	}
+
+	answer := strings.TrimSpace(msg.Text)
+	previous, err := poll.AddResponse(msg.User, answer)
+	if err != nil {
+		return true, robot.ReplyInThread(msg.Channel, msg.ThreadTS, fmt.Sprintf("<@%s> %s", msg.User, answerErrorMessage(err)))
+	}
+
+	if err := refreshPostedPoll(robot, poll); err != nil {
+		return true, err
+	}
+	return true, followAnswer(robot, msg.User, poll, msg.User, previous)
+}
+
+// followAnswer is what happens next once an answer is recorded. A follow up question is asked if the answer
+// has one, otherwise a survey moves on to the next question
+func followAnswer(robot *Robot, channel string, poll *Poll, userID string, previous *PollResponse) error {
+	asked, err := askFollowUp(robot, channel, poll, userID, previous)
+	if err != nil || asked {
+		return err
+	}
+	return advanceSurvey(robot, channel, poll, userID)
+}
+
+// askFollowUp asks the follow up question for the user's new answer if there is one. Anonymous polls never get
+// follow ups since the follow up would have to know who gave the answer
+func askFollowUp(robot *Robot, channel string, poll *Poll, userID string, previous *PollResponse) (bool, error) {
+	if poll.Kind != ResponsePoll || poll.Anonymous {
+		return false, nil
+	}
+
+	// Giving the same answer again does not ask the follow up a second time
+	response := poll.CurrentResponse(userID)
+	if response.ID == 0 || (previous != nil && previous.ID == response.ID) {
+		return false, nil
+	}
 
-	return robot.SendMessage(msg.Channel, "Thanks for responding!")
+	followUp := findFollowUpForAnswer(poll.ID, response.Value)
+	if followUp.ID == 0 {
+		return false, nil
+	}
+
+	pending := FollowUpResponse{FollowUpQuestionID: followUp.ID, PollResponseID: response.ID}
+	if err := GetDB().Create(&pending).Error; err != nil {
+		return false, err
+	}
+	return true, robot.SendMessage(channel, fmt.Sprintf("%s\nJust reply here or use `answer follow up %s {answer}`", followUp.Question, poll.UUID))
+}
+
+// answeredMessage lets the user know whether we recorded a new answer or changed the one they gave before
+func answeredMessage(previous *PollResponse, answer string) string {
+	switch {
+	case previous == nil:
+		return "Thanks for responding!"
+	case previous.Value == answer:
+		return fmt.Sprintf("You already answered %s, nothing to change", answer)
+	default:
+		return fmt.Sprintf("Thanks, I've updated your answer from %s to %s", previous.Value, answer)
+	}
+}
+
+// answerErrorMessage explains to the user why their answer was turned away
+func answerErrorMessage(err error) string {
+	if outOfRange, ok := err.(*ErrScoreOutOfRange); ok {
+		return fmt.Sprintf("Sorry, answer with a number from %d to %d", outOfRange.Min, outOfRange.Max)
+	}
+
+	switch err {
+	case ErrPollClosed:
+		return "Sorry, that poll has closed and is no longer accepting answers"
+	case ErrAlreadyAnswered:
+		return "You have already answered this anonymous poll. Anonymous answers can't be changed"
+	case ErrNotRecipient:
+		return "Sorry, this poll was not sent to you"
+	}
+
+	logrus.Error(err)
+	return "We were unable to add your response"
 }
 
 func showPoll(robot *Robot, msg *Message, captureGroups []string) error {
 	uuid := captureGroups[1]
-	poll, err := FindFirstActivePollByUUID(uuid)
+	poll, err := FindFirstPollWithResultsByUUID(uuid)
 	if err != nil {
 		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a poll %s", uuid))
 		return err
@@ -186,39 +623,105 @@ func showPoll(robot *Robot, msg *Message, captureGroups []string) error {
 	return robot.PostMessage(msg.Channel, "", attachment)
 }
 
-func cancelPoll(robot *Robot, msg *Message, captureGroups []string) error {
-	uuid := strings.TrimSpace(captureGroups[1])
+// findCreatorsPoll looks up a poll in any stage making sure it belongs to the person asking
+func findCreatorsPoll(robot *Robot, msg *Message, uuid, action string) (*Poll, error) {
 	poll := &Poll{}
 	GetDB().Where("uuid = ? ", uuid).First(poll)
 	if poll.ID == 0 {
 		robot.SendMessage(msg.Channel, "Oops, couldn't find the poll for you")
-		return fmt.Errorf("Unable to find poll with uuid %s", uuid)
+		return nil, fmt.Errorf("Unable to find poll with uuid %s", uuid)
+	}
+
+	if poll.Creator != msg.User {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry, only the person who created the poll can %s it", action))
+		return nil, ErrNotPollCreator
+	}
+	return poll, nil
+}
+
+// cancelPoll stops a poll in its tracks. The poll and any answers are kept so we can look back at them
+func cancelPoll(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := strings.TrimSpace(captureGroups[1])
+	poll, err := findCreatorsPoll(robot, msg, uuid, "cancel")
+	if err != nil {
+		return err
+	}
+
+	// Recipients work through a sent survey by position so taking a question out would move them all along
+	if poll.SurveyID != nil && poll.isPublished() {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is a question of a survey which has been sent, questions can't be cancelled once the survey is out", uuid))
+	}
+
+	if err := poll.TransitionTo(StageCancelled, msg.User); err != nil {
+		if err == ErrIllegalTransition {
+			return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is %s and can no longer be cancelled", uuid, poll.Stage))
+		}
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
 	}
 
-	if err := GetDB().Delete(poll).Error; err != nil {
+	// No point sending out fresh copies of a poll that was called off
+	if err := DeleteSchedulesByPoll(poll); err != nil {
 		return err
 	}
 
+	if err := refreshPostedPoll(robot, poll); err != nil {
+		logrus.WithField("poll_uuid", poll.UUID).Error("Error updating the posted poll: ", err)
+	}
+
 	return robot.SendMessage(msg.Channel, "Okay, cancelling the poll for you")
 }
 
+func archivePoll(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := strings.TrimSpace(captureGroups[1])
+	poll, err := findCreatorsPoll(robot, msg, uuid, "archive")
+	if err != nil {
+		return err
+	}
+
+	if err := poll.TransitionTo(StageArchived, msg.User); err != nil {
+		if err == ErrIllegalTransition {
+			return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is %s, only active or closed polls can be archived", uuid, poll.Stage))
+		}
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	// Archived polls are put away for good so they shouldn't keep sending copies either
+	if err := DeleteSchedulesByPoll(poll); err != nil {
+		return err
+	}
+
+	if err := refreshPostedPoll(robot, poll); err != nil {
+		logrus.WithField("poll_uuid", poll.UUID).Error("Error updating the posted poll: ", err)
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, archived poll %s. You can still find it with `list archived polls`", uuid))
+}
+
 func getQuestion(robot *Robot, msg *Message, poll *Poll) error {
 	nextStage := ""
 	response := ""
 
+	poll.Question = msg.Text
+	if poll.isEditing() {
+		return returnFromEdit(robot, msg, poll)
+	}
+
 	switch poll.Kind {
 	case FeedbackPoll:
-		nextStage = "getRecipients"
-		response = "Who should we send this to?"
-	case ResponsePoll:
-		nextStage = "getAnswers"
-		response = "What are the possible responses (comma separated)?"
+		return questionComplete(robot, msg, poll)
+	case ResponsePoll, MultipleChoicePoll:
+		nextStage = StageGetAnswers
+		response = answersPrompt
+	case ScalePoll:
+		nextStage = StageGetScale
+		response = scalePrompt
 	default:
 		logrus.Panic("Unknown kind of poll %s", poll.Kind)
 	}
 
-	poll.Question = msg.Text
-	if err := poll.TransitionTo(nextStage); err != nil {
+	if err := poll.TransitionTo(nextStage, msg.User); err != nil {
 		return err
 	}
 	return robot.SendMessage(msg.Channel, response)
@@ -232,47 +735,814 @@ func getAnswers(robot *Robot, msg *Message, poll *Poll) error {
 		answers = append(answers, PossibleAnswer{Value: answer})
 	}
 
-	poll.PossibleAnswers = answers
-	if err := poll.TransitionTo("getRecipients"); err != nil {
+	if err := poll.SetAnswers(answers); err != nil {
+		robot.SendMessage(msg.Channel, "Error saving the answers. Try again to set the possible responses")
+		return err
+	}
+
+	if poll.isEditing() {
+		return returnFromEdit(robot, msg, poll)
+	}
+	return questionComplete(robot, msg, poll)
+}
+
+func getScale(robot *Robot, msg *Message, poll *Poll) error {
+	scale, err := parseScale(msg.Text)
+	if err != nil {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("%s. %s", err, scalePrompt))
+	}
+
+	poll.ScaleMin = scale.Min
+	poll.ScaleMax = scale.Max
+	poll.ScaleMinLabel = scale.MinLabel
+	poll.ScaleMaxLabel = scale.MaxLabel
+	if poll.isEditing() {
+		return returnFromEdit(robot, msg, poll)
+	}
+	return questionComplete(robot, msg, poll)
+}
+
+// questionComplete moves the poll along once the question and how to answer it are filled in. A survey
+// question is finished at this point while a poll still needs to know who to send it to
+func questionComplete(robot *Robot, msg *Message, poll *Poll) error {
+	if poll.SurveyID == nil {
+		if err := poll.TransitionTo(StageGetRecipients, msg.User); err != nil {
+			return err
+		}
+		return robot.SendMessage(msg.Channel, recipientsPromptFor(poll))
+	}
+
+	if err := poll.TransitionTo(StageSurveyQuestion, msg.User); err != nil {
+		return err
+	}
+
+	survey, err := FindSurveyByID(*poll.SurveyID)
+	if err != nil {
 		return err
 	}
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Added question %d to %s. Add another with `add {kind} question to survey %s` or send it with `send survey %s to {recipients}`", poll.Position+1, survey.Title, survey.UUID, survey.UUID))
+}
 
-	return robot.SendMessage(msg.Channel, "Who should we send this to?")
+// recipientsPromptFor asks who gets the poll, for channel polls that is everyone in the channel it is posted in
+func recipientsPromptFor(poll *Poll) string {
+	if poll.postsInChannel() {
+		return postChannelPrompt
+	}
+	return recipientsPrompt
 }
 
 func getRecipients(robot *Robot, msg *Message, poll *Poll) error {
-	recipients := parseRecpientsText(robot, *msg)
+	recipients, unresolved := resolveRecipients(robot, *msg, poll.Creator)
+	if len(unresolved) > 0 {
+		return robot.SendMessage(msg.Channel, unresolvedMessage(unresolved)+recipientsPromptFor(poll))
+	}
+
+	if len(recipients) == 0 {
+		return robot.SendMessage(msg.Channel, "There is nobody left to send the poll to. "+recipientsPromptFor(poll))
+	}
+
+	if poll.postsInChannel() {
+		channel, ok := findPostChannel(robot, msg.Text)
+		if !ok {
+			return robot.SendMessage(msg.Channel, "Mention exactly one channel to post the poll in. "+postChannelPrompt)
+		}
+		poll.PostChannel = channel
+	}
 
 	if err := poll.SetRecipients(recipients); err != nil {
 		robot.SendMessage(msg.Channel, "Had trouble setting the recipients. Make sure they are valid channel names and try again")
 		return err
 	}
 
-	if err := poll.TransitionTo("sendPoll"); err != nil {
+	if poll.isEditing() {
+		return returnFromEdit(robot, msg, poll)
+	}
+
+	if err := poll.TransitionTo(StageGetCloseTime, msg.User); err != nil {
 		robot.SendMessage(msg.Channel, "Error saving the poll. Try again to set the recpients")
 		return err
 	}
-	return robot.PostMessage(msg.Channel, "Here's a preview of what we are going to send:", poll.SlackPreviewAttachment())
+	return robot.SendMessage(msg.Channel, closeTimePrompt)
 }
 
-func sendPoll(robot *Robot, msg *Message, poll *Poll) error {
-	if !strings.EqualFold(msg.Text, "yes") {
-		return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay not going to send poll. You can cancel with `cancel poll %s`", poll.UUID))
+func getCloseTime(robot *Robot, msg *Message, poll *Poll) error {
+	closesAt, err := parseCloseTime(msg.Text, time.Now())
+	if err != nil {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("%s. %s", err, closeTimePrompt))
 	}
 
-	if err := poll.TransitionTo("active"); err != nil {
+	poll.ClosesAt = closesAt
+	if err := poll.TransitionTo(StageSendPoll, msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Error saving the poll. Try again to set the close time")
 		return err
 	}
+	return promptForStage(robot, msg.Channel, poll)
+}
 
-	recipients, err := poll.GetRecipients()
+func listDrafts(robot *Robot, msg *Message, captureGroups []string) error {
+	drafts, err := FindDraftsByMessage(msg)
 	if err != nil {
-		robot.SendMessage(msg.Channel, "hummmmm something seems to be wrong with getting the list of recipients")
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
 		return err
 	}
 
-	for _, recipient := range recipients {
-		robot.PostMessage(recipient.SlackID, "", poll.SlackRecipientAttachment())
+	if len(drafts) == 0 {
+		return robot.SendMessage(msg.Channel, "You have no polls being created")
+	}
+
+	current, _ := FindFirstInactivePollByMessage(msg)
+
+	var result bytes.Buffer
+	for k, v := range drafts {
+		question := v.Question
+		if question == "" {
+			question = "No question yet"
+		}
+
+		result.WriteString(fmt.Sprintf("%d. %s - %s poll - id:%s", k+1, question, v.Kind, v.UUID))
+		if v.ID == current.ID {
+			result.WriteString(" (current)")
+		}
+		result.WriteString("\n")
+	}
+
+	return robot.PostMessage(msg.Channel, "Here are the polls you are creating. Switch between them with `resume poll {poll_uuid}`:", Attachment{Text: result.String()})
+}
+
+// resumePoll switches which draft the creator's conversation in the channel continues
+func resumePoll(robot *Robot, msg *Message, captureGroups []string) error {
+	poll, err := findCreatorsPoll(robot, msg, captureGroups[1], "resume")
+	if err != nil {
+		return err
+	}
+
+	if !poll.isBeingCreated() {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is %s, only polls being created can be resumed", poll.UUID, poll.Stage))
+	}
+
+	if poll.Channel != msg.Channel {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s was started in another channel, resume it from there", poll.UUID))
+	}
+
+	if err := SelectDraft(poll); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, carrying on with poll %s", poll.UUID))
+	return promptForStage(robot, msg.Channel, poll)
+}
+
+// resolveRecipients works out who a poll goes to from the creator's reply e.g `#engineering except @alice`.
+// Anyone mentioned after except is left out along with bots, deactivated accounts and the creator, unless the
+// creator mentions themselves directly
+func resolveRecipients(robot *Robot, msg Message, creator string) ([]Recipient, []string) {
+	parts := exceptRegex.Split(msg.Text, 2)
+	included, unresolved := parseRecpientsText(robot, Message{Text: parts[0], Channel: msg.Channel})
+
+	excluded := make(map[string]bool)
+	if len(parts) == 2 {
+		exceptions, missing := parseRecpientsText(robot, Message{Text: parts[1], Channel: msg.Channel})
+		unresolved = append(unresolved, missing...)
+		for _, exception := range exceptions {
+			excluded[exception.SlackID] = true
+		}
+	}
+
+	mentioned := make(map[string]bool)
+	direct, _ := findUsers(robot, Message{Text: parts[0]})
+	for _, recipient := range direct {
+		mentioned[recipient.SlackID] = true
+	}
+
+	recipients := []Recipient{}
+	for _, recipient := range included {
+		switch {
+		case excluded[recipient.SlackID]:
+		case recipient.SlackID == creator && !mentioned[creator]:
+		case !robot.canReceivePolls(recipient.SlackID):
+		default:
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients, unresolved
+}
+
+// canReceivePolls is false for bots, Carlos included, and deactivated accounts. Anyone Carlos hasn't
+// downloaded the details of yet is given the benefit of the doubt
+func (robot *Robot) canReceivePolls(slackID string) bool {
+	if slackID == robot.ID {
+		return false
+	}
+
+	user, ok := robot.Users[slackID]
+	return !ok || !(user.Deleted || user.IsBot)
+}
+
+// unresolvedMessage tells the creator which mentions couldn't be turned into recipients
+func unresolvedMessage(unresolved []string) string {
+	return fmt.Sprintf("I couldn't find %s. Make sure I'm in any channels you mention. ", strings.Join(unresolved, ", "))
+}
+
+// promptForStage asks the creator for whatever the poll's current stage needs. Once everything is filled in
+// that is confirming the preview
+func promptForStage(robot *Robot, channel string, poll *Poll) error {
+	switch poll.Stage {
+	case StageGetQuestion:
+		return robot.SendMessage(channel, questionPrompt)
+	case StageGetAnswers:
+		return robot.SendMessage(channel, answersPrompt)
+	case StageGetScale:
+		return robot.SendMessage(channel, scalePrompt)
+	case StageGetRecipients:
+		return robot.SendMessage(channel, recipientsPromptFor(poll))
+	case StageGetCloseTime:
+		return robot.SendMessage(channel, closeTimePrompt)
+	case StageSendPoll:
+		return robot.PostMessage(channel, "Here's a preview of what we are going to send:", poll.SlackPreviewAttachment())
+	}
+	return nil
+}
+
+// returnFromEdit takes the poll back to where the creator was before they went back to change it
+func returnFromEdit(robot *Robot, msg *Message, poll *Poll) error {
+	if err := poll.ReturnFromEdit(msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Error saving the poll. Try making the change again")
+		return err
+	}
+	return promptForStage(robot, msg.Channel, poll)
+}
+
+func editQuestion(robot *Robot, msg *Message, captureGroups []string) error {
+	return editPoll(robot, msg, func(poll *Poll) string { return StageGetQuestion })
+}
+
+func editAnswers(robot *Robot, msg *Message, captureGroups []string) error {
+	return editPoll(robot, msg, func(poll *Poll) string {
+		if poll.Kind == FeedbackPoll {
+			return ""
+		}
+		return poll.answersStage()
+	})
+}
+
+func editRecipients(robot *Robot, msg *Message, captureGroups []string) error {
+	return editPoll(robot, msg, func(poll *Poll) string { return StageGetRecipients })
+}
+
+func goBack(robot *Robot, msg *Message, captureGroups []string) error {
+	return editPoll(robot, msg, (*Poll).stageBefore)
+}
+
+// editPoll takes the poll being created back to an earlier stage so the creator can change it. Once they
+// have the poll carries on from where they left off
+func editPoll(robot *Robot, msg *Message, stageFor func(*Poll) string) error {
+	poll, err := FindFirstInactivePollByMessage(msg)
+	if err != nil {
+		return robot.SendMessage(msg.Channel, "There is no poll being created to edit. Start one with `create {kind} poll`")
+	}
+
+	stage := stageFor(poll)
+	if stage == poll.Stage {
+		return promptForStage(robot, msg.Channel, poll)
+	}
+
+	if creationOrder[stage] == 0 {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("There is nothing to go back to. You can cancel the poll with `cancel poll %s`", poll.UUID))
+	}
+
+	// Only parts of the poll which have already been filled in can be changed
+	if creationOrder[stage] > creationOrder[poll.Stage] {
+		return robot.SendMessage(msg.Channel, "You haven't got to that part of the poll yet")
+	}
+
+	if err := poll.TransitionTo(stage, msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Error saving the poll. Try again to edit it")
+		return err
+	}
+	return promptForStage(robot, msg.Channel, poll)
+}
+
+func sendPoll(robot *Robot, msg *Message, poll *Poll) error {
+	if !strings.EqualFold(msg.Text, "yes") {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay not going to send poll. You can cancel with `cancel poll %s`", poll.UUID))
+	}
+
+	if err := poll.TransitionTo(StageActive, msg.User); err != nil {
+		return err
+	}
+
+	report, err := deliverPoll(robot, poll)
+	if err != nil {
+		// Nobody got the poll so it goes back to waiting to be sent and the creator can try again
+		if undoErr := poll.undoSend(msg.User); undoErr != nil {
+			logrus.WithField("poll_uuid", poll.UUID).Error("Unable to put the poll back to waiting to be sent: ", undoErr)
+		}
+		robot.SendMessage(msg.Channel, "hummmmm something went wrong sending the poll so it hasn't gone out. Reply `yes` to try again")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll is live you can check in by asking me to `show poll %s`", poll.UUID)+report.Summary())
+}
+
+// DeliveryReport is how sending a poll to each of its recipients went
+type DeliveryReport struct {
+	Delivered []string
+	Failed    map[string]error
+}
+
+// Summary tells the creator who didn't get the poll and why. Nothing needs saying when everyone got it
+func (report *DeliveryReport) Summary() string {
+	if report == nil || len(report.Failed) == 0 {
+		return ""
+	}
+
+	failed := []string{}
+	for id, err := range report.Failed {
+		failed = append(failed, fmt.Sprintf("<@%s> (%s)", id, err))
+	}
+	sort.Strings(failed)
+
+	total := len(report.Delivered) + len(report.Failed)
+	return fmt.Sprintf("\nI couldn't deliver the poll to %d of %d recipients: %s", len(report.Failed), total, strings.Join(failed, ", "))
+}
+
+// deliverPoll sends the poll to each of its recipients. A recipient who can't be reached doesn't stop the rest
+// from getting the poll, the report says who was missed
+func deliverPoll(robot *Robot, poll *Poll) (*DeliveryReport, error) {
+	report := &DeliveryReport{Failed: make(map[string]error)}
+
+	if poll.postsInChannel() {
+		posted, err := robot.postAttachments(poll.PostChannel, "", []Attachment{poll.SlackChannelAttachment()})
+		if err != nil {
+			return nil, err
+		}
+
+		// Slack hands back the channel id even when we posted using the name
+		poll.PostChannel = posted.Channel
+		poll.PostTS = posted.TS
+		return report, poll.Save()
+	}
+
+	recipients, err := poll.GetRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recipient := range recipients {
+		if err := robot.PostMessage(recipient.SlackID, "", poll.SlackRecipientAttachment()); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"poll_id":   poll.ID,
+				"recipient": recipient.SlackID,
+			}).Error("Unable to deliver poll: ", err)
+			report.Failed[recipient.SlackID] = err
+			continue
+		}
+		report.Delivered = append(report.Delivered, recipient.SlackID)
+	}
+	return report, nil
+}
+
+func schedulePoll(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := captureGroups[1]
+	poll := &Poll{}
+	GetDB().Where("uuid = ? AND creator = ?", uuid, msg.User).First(poll)
+	if poll.ID == 0 {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a poll %s", uuid))
+		return fmt.Errorf("Unable to find poll with uuid %s", uuid)
+	}
+
+	// The poll only has to be active when it is scheduled, copies carry on going out after it closes so a weekly
+	// question keeps being asked until it is unscheduled or archived
+	if poll.Stage != StageActive {
+		return robot.SendMessage(msg.Channel, "Only polls that have been sent can be scheduled")
+	}
+
+	if poll.SurveyID != nil {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is a question of a survey, questions of a survey can't be scheduled on their own", poll.UUID))
+	}
+
+	schedule, err := NewPollSchedule(poll, captureGroups[2], time.Now())
+	if err != nil {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("I couldn't understand that schedule: %s", err))
+	}
+
+	if err := schedule.Save(); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, I will send a copy of this poll on the schedule `%s`. The next one goes out %s. You can stop it with `unschedule poll %s`", schedule.Cron, schedule.NextRunAt.Format(time.RFC1123), poll.UUID))
+}
+
+func unschedulePoll(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := captureGroups[1]
+	poll := &Poll{}
+	GetDB().Where("uuid = ? AND creator = ?", uuid, msg.User).First(poll)
+	if poll.ID == 0 {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a poll %s", uuid))
+		return fmt.Errorf("Unable to find poll with uuid %s", uuid)
+	}
+
+	if err := DeleteSchedulesByPoll(poll); err != nil {
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, "Okay, no more copies of that poll will be sent")
+}
+
+func findCreatorsActivePoll(robot *Robot, msg *Message, uuid string) (*Poll, error) {
+	poll, err := FindFirstActivePollByUUID(uuid)
+	if err != nil || poll.Creator != msg.User {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find an active poll %s", uuid))
+		return nil, fmt.Errorf("Unable to find active poll with uuid %s for %s", uuid, msg.User)
+	}
+	return poll, nil
+}
+
+func remindPoll(robot *Robot, msg *Message, captureGroups []string) error {
+	poll, err := findCreatorsActivePoll(robot, msg, captureGroups[1])
+	if err != nil {
+		return err
+	}
+
+	report, err := poll.Remind(robot, time.Now(), true)
+	if err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if len(report.Failed) > 0 && len(report.Delivered) == 0 {
+		return robot.SendMessage(msg.Channel, "Sorry, I couldn't remind anyone."+report.Summary())
+	}
+
+	nudged := len(report.Delivered)
+	if nudged == 0 {
+		if unanswered, err := poll.UnansweredRecipients(); err == nil && len(unanswered) == 0 {
+			return robot.SendMessage(msg.Channel, "Everyone has already answered, nobody to remind!")
+		}
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Nobody to remind right now. Everyone left has been reminded in the last %s or %d times already", minReminderEvery, maxReminders))
+	}
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, I reminded %d people who have not answered yet", nudged)+report.Summary())
+}
+
+func setPollReminders(robot *Robot, msg *Message, captureGroups []string) error {
+	poll, err := findCreatorsActivePoll(robot, msg, captureGroups[1])
+	if err != nil {
+		return err
+	}
+
+	interval := strings.TrimSpace(captureGroups[2])
+	if strings.EqualFold(interval, "never") {
+		poll.RemindEvery = 0
+		if err := poll.Save(); err != nil {
+			return err
+		}
+		return robot.SendMessage(msg.Channel, "Okay, I won't send any more reminders for this poll")
+	}
+
+	every, err := parseDuration(interval)
+	if err != nil || every < minReminderEvery {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Reminders need an interval of at least %s like `1d` or `12h`", minReminderEvery))
+	}
+
+	poll.RemindEvery = every
+	if err := poll.Save(); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, I will remind people who have not answered every %s, up to %d times", every, maxReminders))
+}
+
+func createSurvey(robot *Robot, msg *Message, captureGroups []string) error {
+	survey := NewSurvey(strings.TrimSpace(captureGroups[1]), msg.User, msg.Channel)
+	if err := survey.Save(); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Creating survey %s. Add questions one at a time with `add {feedback|response|multiple|scale} question to survey %s`", survey.Title, survey.UUID))
+}
+
+// findCreatorsSurvey looks up a survey making sure it belongs to the person asking
+func findCreatorsSurvey(robot *Robot, msg *Message, uuid string) (*Survey, error) {
+	survey, err := FindSurveyByUUID(uuid)
+	if err != nil || survey.Creator != msg.User {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a survey %s", uuid))
+		return nil, fmt.Errorf("Unable to find survey with uuid %s for %s", uuid, msg.User)
+	}
+	return survey, nil
+}
+
+func addSurveyQuestion(robot *Robot, msg *Message, captureGroups []string) error {
+	kind := captureGroups[1]
+	survey, err := findCreatorsSurvey(robot, msg, captureGroups[2])
+	if err != nil {
+		return err
+	}
+
+	if !validPollKind(kind) {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Question must be of type response, multiple, scale or feedback cannot be %s", kind))
+		return ErrInvalidPollType
+	}
+
+	poll, err := survey.AddQuestion(kind, msg.Channel)
+	if err == ErrSurveyAlreadySent {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Survey %s has already been sent so questions can't be added", survey.UUID))
+	} else if err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if err := poll.TransitionTo(StageGetQuestion, msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if err := SelectDraft(poll); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Adding a %s question to %s. You can cancel the question any time with `cancel poll %s`\n%s", kind, survey.Title, poll.UUID, questionPrompt))
+}
+
+func sendSurvey(robot *Robot, msg *Message, captureGroups []string) error {
+	survey, err := findCreatorsSurvey(robot, msg, captureGroups[1])
+	if err != nil {
+		return err
+	}
+
+	recipients, unresolved := resolveRecipients(robot, Message{Text: captureGroups[2], Channel: msg.Channel}, survey.Creator)
+	if len(unresolved) > 0 {
+		return robot.SendMessage(msg.Channel, unresolvedMessage(unresolved)+"Mention the people or channels the survey should go to")
+	}
+
+	if len(recipients) == 0 {
+		return robot.SendMessage(msg.Channel, "I couldn't find anyone to send the survey to. Mention the people or channels it should go to")
+	}
+
+	progress, err := survey.Send(recipients, msg.User)
+	switch {
+	case err == ErrSurveyAlreadySent:
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Survey %s has already been sent", survey.UUID))
+	case err == ErrEmptySurvey:
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Survey %s has no questions yet. Add one with `add {kind} question to survey %s`", survey.UUID, survey.UUID))
+	case err != nil:
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Unable to send the survey: %s", err))
+		return err
+	}
+
+	for i := range progress {
+		if err := sendSurveyQuestion(robot, progress[i].SlackID, survey, &progress[i]); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"survey_uuid": survey.UUID,
+				"recipient":   progress[i].SlackID,
+			}).Error("Error sending survey question: ", err)
+		}
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Survey is live you can check in by asking me to `show survey %s`", survey.UUID))
+}
+
+func showSurvey(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := captureGroups[1]
+	survey, err := FindSurveyByUUID(uuid)
+	if err != nil {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a survey %s", uuid))
+		return err
+	}
+
+	attachments, err := survey.SlackSurveySummary()
+	if err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+	return robot.PostAttachments(msg.Channel, "", attachments)
+}
+
+func resumeSurvey(robot *Robot, msg *Message, captureGroups []string) error {
+	progress := FindCurrentSurveyProgress(msg.User)
+	if progress.ID == 0 {
+		return robot.SendMessage(msg.Channel, "You have no surveys waiting for answers")
+	}
+
+	survey, err := FindSurveyByID(progress.SurveyID)
+	if err != nil {
+		return err
+	}
+	return sendSurveyQuestion(robot, msg.Channel, survey, progress)
+}
+
+// sendSurveyQuestion sends the recipient the question they are up to or thanks them once they are done
+func sendSurveyQuestion(robot *Robot, channel string, survey *Survey, progress *SurveyProgress) error {
+	question, total, err := survey.questionFor(progress)
+	if err != nil {
+		return err
+	}
+
+	if question == nil {
+		return robot.SendMessage(channel, fmt.Sprintf("That's everything for %s, thanks for filling it in!", survey.Title))
+	}
+	return robot.PostMessage(channel, "", survey.SlackQuestionAttachment(question, total))
+}
+
+// advanceSurvey moves the recipient on to the next question once they answer the one they are up to. Going back
+// and changing an earlier answer leaves them where they were
+func advanceSurvey(robot *Robot, channel string, poll *Poll, userID string) error {
+	if poll.SurveyID == nil {
+		return nil
+	}
+
+	progress := FindSurveyProgress(*poll.SurveyID, userID)
+	if progress.ID == 0 || progress.CompletedAt != nil || progress.Position != poll.Position {
+		return nil
+	}
+
+	survey, err := FindSurveyByID(*poll.SurveyID)
+	if err != nil {
+		return err
+	}
+
+	_, total, err := survey.questionFor(progress)
+	if err != nil {
+		return err
+	}
+
+	if err := progress.Advance(total, time.Now()); err != nil {
+		return err
+	}
+	return sendSurveyQuestion(robot, channel, survey, progress)
+}
+
+// continueSurvey treats a direct message which is not a command as the answer to the survey question the
+// user is up to. False is returned when they are not part way through a survey
+func continueSurvey(robot *Robot, msg *Message) (bool, error) {
+	progress := FindCurrentSurveyProgress(msg.User)
+	if progress.ID == 0 {
+		return false, nil
+	}
+
+	survey, err := FindSurveyByID(progress.SurveyID)
+	if err != nil {
+		return true, err
+	}
+
+	question, _, err := survey.questionFor(progress)
+	if err != nil || question == nil {
+		return true, err
+	}
+
+	previous, err := question.AddResponse(msg.User, msg.Text)
+	if err != nil {
+		return true, robot.SendMessage(msg.Channel, answerErrorMessage(err))
+	}
+
+	if err := robot.SendMessage(msg.Channel, answeredMessage(previous, question.NormaliseResponse(msg.Text))); err != nil {
+		return true, err
+	}
+	return true, followAnswer(robot, msg.Channel, question, msg.User, previous)
+}
+
+func addFollowUp(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := strings.TrimSpace(captureGroups[1])
+	answer := strings.TrimSpace(captureGroups[2])
+	question := strings.TrimSpace(captureGroups[3])
+
+	poll, err := findCreatorsPoll(robot, msg, uuid, "add follow ups to")
+	if err != nil {
+		return err
+	}
+
+	if poll.Kind != ResponsePoll || poll.Anonymous {
+		return robot.SendMessage(msg.Channel, "Follow up questions can only be added to response polls which are not anonymous")
+	}
+
+	if poll.Stage != StageActive && !poll.isBeingCreated() {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll %s is %s, follow ups can only be added until it closes", uuid, poll.Stage))
+	}
+
+	if _, err := poll.AddFollowUp(answer, question); err == ErrUnknownAnswer {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("%s is not one of the possible answers: %s", answer, poll.slackAnswerString()))
+	} else if err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, anyone who answers %s will be asked: %s", answer, question))
+}
+
+func answerFollowUp(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := captureGroups[1]
+	poll, err := FindFirstPublishedPollByUUID(uuid)
+	if err != nil {
+		robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a poll %s", uuid))
+		return err
+	}
+
+	pending := FindPendingFollowUpForPoll(msg.User, poll.ID)
+	if pending.ID == 0 {
+		return robot.SendMessage(msg.Channel, "There is no follow up question waiting on you for that poll")
+	}
+	return finishFollowUp(robot, msg.Channel, msg.User, pending, captureGroups[2])
+}
+
+// continueFollowUp treats a direct message which is not a command as the answer to a follow up the user was
+// asked. False is returned when there is no follow up waiting on them
+func continueFollowUp(robot *Robot, msg *Message) (bool, error) {
+	pending := FindPendingFollowUp(msg.User)
+	if pending.ID == 0 {
+		return false, nil
+	}
+	return true, finishFollowUp(robot, msg.Channel, msg.User, pending, msg.Text)
+}
+
+func finishFollowUp(robot *Robot, channel, userID string, pending *FollowUpResponse, answer string) error {
+	if err := pending.Answer(strings.TrimSpace(answer), time.Now()); err != nil {
+		robot.SendMessage(channel, "We were unable to add your response")
+		return err
+	}
+
+	if err := robot.SendMessage(channel, "Thanks for the extra detail!"); err != nil {
+		return err
+	}
+
+	// Surveys wait for the follow up before moving on to the next question
+	poll, err := pending.Poll()
+	if err != nil {
+		return err
+	}
+	return advanceSurvey(robot, channel, poll, userID)
+}
+
+func savePollTemplate(robot *Robot, msg *Message, captureGroups []string) error {
+	uuid := captureGroups[1]
+	name := strings.TrimSpace(captureGroups[2])
+
+	poll, err := findCreatorsPoll(robot, msg, uuid, "save")
+	if err != nil {
+		return err
+	}
+
+	if poll.SurveyID != nil || !poll.isPublished() {
+		return robot.SendMessage(msg.Channel, "Only active or closed polls can be saved as a template")
+	}
+
+	if _, err := SaveTemplate(poll, name); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	return robot.SendMessage(msg.Channel, fmt.Sprintf("Okay, saved poll %s as template %s. Start a new one with `create poll from template %s`", uuid, name, name))
+}
+
+func listTemplates(robot *Robot, msg *Message, captureGroups []string) error {
+	templates, err := FindTemplatesByCreator(msg.User)
+	if err != nil {
+		return err
+	}
+
+	if len(templates) == 0 {
+		return robot.SendMessage(msg.Channel, "You have no templates. Save one with `save poll {poll_uuid} as template {name}`")
+	}
+
+	var result bytes.Buffer
+	for k, template := range templates {
+		question := ""
+		if poll, err := template.Poll(); err == nil {
+			question = poll.Question
+		}
+		result.WriteString(fmt.Sprintf("%d. %s - %s\n", k+1, template.Name, question))
+	}
+
+	return robot.PostMessage(msg.Channel, "Here are your templates:", Attachment{Text: result.String()})
+}
+
+func createPollFromTemplate(robot *Robot, msg *Message, captureGroups []string) error {
+	name := strings.TrimSpace(captureGroups[1])
+	template := FindTemplate(msg.User, name)
+	if template.ID == 0 {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Sorry about this but didn't not find a template %s", name))
+	}
+
+	poll, err := template.NewPoll(msg.Channel)
+	if err == ErrTemplateFromDraft {
+		return robot.SendMessage(msg.Channel, fmt.Sprintf("Template %s was saved from a poll which hasn't been sent yet. Save it again once the poll is active", name))
+	} else if err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if err := poll.TransitionTo(StageSendPoll, msg.User); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
+	}
+
+	if err := SelectDraft(poll); err != nil {
+		robot.SendMessage(msg.Channel, "Something has gone wrong. We are looking into it.")
+		return err
 	}
 
-	return robot.SendMessage(msg.Channel, fmt.Sprintf("Poll is live you can check in by asking me to `show poll %s`", poll.UUID))
+	robot.SendMessage(msg.Channel, fmt.Sprintf("Creating a %s poll from template %s. You can cancel the poll any time with `cancel poll %s`", poll.Kind, name, poll.UUID))
+	return promptForStage(robot, msg.Channel, poll)
 }
@@ -1,14 +1,13 @@
 package slackbot
 
 import (
-	"bytes"
+	"errors"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dklassen/CarlosTheCurious/uuid"
-
-	"golang.org/x/net/websocket"
 )
 
 type BySlackID []Recipient
@@ -21,9 +20,12 @@ func TestParseRecipientsText(t *testing.T) {
 	robot := CleanSetup()
 
 	var tests = []struct {
-		Input         Message
-		InputChannels map[string]Channel
-		Expected      []Recipient
+		Input              Message
+		InputChannels      map[string]Channel
+		InputGroups        map[string]Group
+		InputUserGroups    map[string]UserGroup
+		Expected           []Recipient
+		ExpectedUnresolved []string
 	}{
 		{
 			// identify a single user id
@@ -47,11 +49,39 @@ func TestParseRecipientsText(t *testing.T) {
 				{SlackID: "Uderp2"},
 			},
 		},
+		{
+			// Private groups and user groups are expanded to their members
+			Input: Message{Text: "<#G1PRIVATE|secret_plans> <!subteam^S0DEVS|@devs>"},
+			InputGroups: map[string]Group{
+				"G1PRIVATE": Group{Name: "secret_plans", Members: []string{"Uderp1"}},
+			},
+			InputUserGroups: map[string]UserGroup{
+				"S0DEVS": UserGroup{Handle: "devs", Users: []string{"Uderp1", "Uderp3"}},
+			},
+			Expected: []Recipient{{SlackID: "Uderp1"}, {SlackID: "Uderp3"}},
+		},
+		{
+			// Channels typed out by name and everyone in the channel the poll is created from
+			Input: Message{Text: "#general, <!here>", Channel: "C2TEAM"},
+			InputChannels: map[string]Channel{
+				"C1U41SHTK": Channel{Name: "general", Members: []string{"Uderp1"}},
+				"C2TEAM":    Channel{Name: "team", Members: []string{"Uderp2"}},
+			},
+			Expected: []Recipient{{SlackID: "Uderp1"}, {SlackID: "Uderp2"}},
+		},
+		{
+			// Anything which can't be resolved is reported back
+			Input:              Message{Text: "<@UDF123> <#C404|gone> #nowhere <!subteam^S404|@ghosts> <!channel>", Channel: "D123"},
+			Expected:           []Recipient{{SlackID: "UDF123"}},
+			ExpectedUnresolved: []string{"#gone", "#nowhere", "@ghosts", "@channel"},
+		},
 	}
 
 	for _, test := range tests {
 		robot.Channels = test.InputChannels
-		result := parseRecpientsText(&robot, test.Input)
+		robot.Groups = test.InputGroups
+		robot.UserGroups = test.InputUserGroups
+		result, unresolved := parseRecpientsText(&robot, test.Input)
 		sort.Sort(BySlackID(result))
 		sort.Sort(BySlackID(test.Expected))
 
@@ -64,6 +94,10 @@ func TestParseRecipientsText(t *testing.T) {
 				t.Fatal("Failed to parse recipient Id. Expected:", v.SlackID, "got:", result[k].SlackID)
 			}
 		}
+
+		if strings.Join(unresolved, ",") != strings.Join(test.ExpectedUnresolved, ",") {
+			t.Fatal("Expected unresolved mentions:", test.ExpectedUnresolved, "got:", unresolved)
+		}
 	}
 }
 
@@ -102,17 +136,11 @@ func TestSlackIDRegex(t *testing.T) {
 }
 
 func TestCreatePoll(t *testing.T) {
-	outgoing := []byte{}
 
 	uuid.GenerateUUID = func() string {
 		return "amazing"
 	}
 
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
-	}
-
 	var testTable = []struct {
 		InputMessage    Message
 		InputCaptures   []string
@@ -126,7 +154,7 @@ func TestCreatePoll(t *testing.T) {
 			InputCaptures:   []string{"", "response"},
 			ExpectedError:   false,
 			ExpectedMessage: []byte("Creating a response poll. You can cancel the poll any time with `cancel poll amazing`\nWhat was the question you wanted to ask?"),
-			ExpectedPoll:    Poll{Stage: "initial", Kind: ResponsePoll},
+			ExpectedPoll:    Poll{Stage: "getQuestion", Kind: ResponsePoll},
 		},
 		// Generate feedback poll
 		{
@@ -134,21 +162,22 @@ func TestCreatePoll(t *testing.T) {
 			InputCaptures:   []string{"", "feedback"},
 			ExpectedError:   false,
 			ExpectedMessage: []byte("Creating a feedback poll. You can cancel the poll any time with `cancel poll amazing`\nWhat was the question you wanted to ask?"),
-			ExpectedPoll:    Poll{Stage: "initial", Kind: FeedbackPoll},
+			ExpectedPoll:    Poll{Stage: "getQuestion", Kind: FeedbackPoll},
 		},
 		// Unable to generate poll
 		{
 			InputMessage:    Message{Text: "bananas", User: "Balony2", Channel: "coffee3"},
 			InputCaptures:   []string{"", "not a known type"},
 			ExpectedError:   true,
-			ExpectedMessage: []byte("Poll must be of type response or feedback cannot be not a known type"),
+			ExpectedMessage: []byte("Poll must be of type response, multiple, scale or feedback cannot be not a known type"),
 			ExpectedPoll:    Poll{},
 		},
 	}
 
 	for _, test := range testTable {
 		robot := CleanSetup()
-		outgoing = []byte("")
+		memory := robot.Transport.(*MemoryTransport)
+		memory.Reset()
 
 		err := createPoll(&robot, &test.InputMessage, test.InputCaptures)
 		if err != nil && test.ExpectedError != true {
@@ -169,44 +198,157 @@ func TestCreatePoll(t *testing.T) {
 			t.Fatal("Expected poll to be of kind:", expectedPoll.Kind, "but got:", poll.Kind)
 		}
 
-		if bytes.Compare(outgoing, test.ExpectedMessage) != 0 {
-			t.Fatal("Expected response message: ", string(test.ExpectedMessage), " got: ", string(outgoing))
+		if memory.SentText() != string(test.ExpectedMessage) {
+			t.Fatal("Expected response message: ", string(test.ExpectedMessage), " got: ", memory.SentText())
 		}
 
 	}
 }
 
-func TestCreatePollReturnsErrorWhenExistingPollIsBeingCreated(t *testing.T) {
+func TestCreateAnonymousPoll(t *testing.T) {
 	robot := CleanSetup()
-	outgoing := []byte{}
+	memory := robot.Transport.(*MemoryTransport)
 
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
+	uuid.GenerateUUID = func() string {
+		return "hush"
 	}
 
-	testMsg := Message{Text: "bananas", User: "Balony2", Channel: "coffee3"}
-	testCaptures := []string{"", "feedback"}
+	testMsg := Message{Text: "create anonymous feedback poll", User: "Balony", Channel: "coffee", DirectMention: true}
+	robot.Dispatch(&testMsg)
+
+	poll, err := FindFirstInactivePollByMessage(&testMsg)
+	if err != nil {
+		t.Fatal("Unable to find poll which was expected to be there")
+	}
+
+	if !poll.Anonymous || poll.Kind != FeedbackPoll {
+		t.Fatal("Expected an anonymous feedback poll got: ", poll)
+	}
+
+	expected := "Creating a feedback poll. You can cancel the poll any time with `cancel poll hush`\nAnswers to this poll are anonymous, nobody including you will be able to see who answered what.\nWhat was the question you wanted to ask?"
+	if memory.SentText() != expected {
+		t.Error("Expected response message: ", expected, " got: ", memory.SentText())
+	}
+
+	memory.Reset()
+	testMsg = Message{Text: "create secret feedback poll", User: "Balony2", Channel: "coffee", DirectMention: true}
+	robot.Dispatch(&testMsg)
+
+	if memory.SentText() != "Sorry I don't know how to make a poll secret" {
+		t.Error("Expected unknown option to be rejected got: ", memory.SentText())
+	}
+}
+
+func TestCreatePollRemindingEvery(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	testMsg := Message{Text: "create open response poll reminding every 1d", User: "Balony", Channel: "coffee", DirectMention: true}
+	robot.Dispatch(&testMsg)
 
-	err := createPoll(&robot, &testMsg, testCaptures)
+	poll, err := FindFirstInactivePollByMessage(&testMsg)
 	if err != nil {
-		t.Fatal("Was not expecting error to be thrown")
+		t.Fatal("Unable to find poll which was expected to be there")
 	}
 
-	err = createPoll(&robot, &testMsg, testCaptures)
-	if err != ErrExistingInactivePoll {
-		t.Fatal("Was expecting a poll to already exist")
+	if !poll.Open || poll.Kind != ResponsePoll || poll.RemindEvery != 24*time.Hour {
+		t.Error("Expected an open response poll reminding every day got: ", poll)
+	}
+
+	memory.Reset()
+	testMsg = Message{Text: "create feedback poll reminding every 5m", User: "Balony2", Channel: "coffee", DirectMention: true}
+	robot.Dispatch(&testMsg)
+
+	expected := "Reminders need an interval of at least 1h0m0s like `1d` or `12h`"
+	if memory.SentText() != expected {
+		t.Error("Expected: ", expected, " got: ", memory.SentText())
 	}
 }
 
-func TestGetQuestion(t *testing.T) {
+func TestRemindersAreCappedPerRecipient(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	poll := &Poll{Kind: FeedbackPoll, UUID: "nudge", Creator: "UBOSS", Channel: "DBOSS", Stage: StageActive, RemindEvery: time.Hour}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+	poll.SetRecipients([]Recipient{{SlackID: "U1"}, {SlackID: "U2"}})
+
+	// U1 has already had every reminder going
+	GetDB().Model(&Recipient{}).Where("poll_id = ? AND slack_id = ?", poll.ID, "U1").UpdateColumn("reminders_sent", maxReminders)
+
+	memory.Reset()
+	sendDueReminders(&robot, time.Now().Add(48*time.Hour))
+
+	posts := memory.Posts()
+	if len(posts) != 1 || posts[0].Channel != "U2" {
+		t.Error("Expected only U2 to be reminded got: ", posts)
+	}
+
+	memory.Reset()
+	remindPoll(&robot, &Message{User: "UBOSS", Channel: "DBOSS"}, []string{"", "nudge"})
+	if len(memory.Posts()) != 0 || !strings.HasPrefix(memory.SentText(), "Nobody to remind right now") {
+		t.Error("Expected U2 to be left alone after being reminded just now got: ", memory.SentText())
+	}
+}
+
+func TestRemindPollReportsRecipientsItCouldNotReach(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	memory.Unreachable = map[string]error{"U2": errors.New("user_disabled")}
+
+	poll := &Poll{Kind: FeedbackPoll, UUID: "nudge", Creator: "UBOSS", Channel: "DBOSS", Stage: StageActive}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+	poll.SetRecipients([]Recipient{{SlackID: "U1"}, {SlackID: "U2"}})
+
+	remindPoll(&robot, &Message{User: "UBOSS", Channel: "DBOSS"}, []string{"", "nudge"})
+
+	expected := "Okay, I reminded 1 people who have not answered yet\nI couldn't deliver the poll to 1 of 2 recipients: <@U2> (user_disabled)"
+	if memory.SentText() != expected {
+		t.Error("Expected: ", expected, " got: ", memory.SentText())
+	}
+
+	if recipient := FindRecipientByID(poll.ID, "U2"); recipient.RemindersSent != 0 {
+		t.Error("Expected the missed reminder not to count against U2 got: ", recipient.RemindersSent)
+	}
+}
+
+func TestCreatePollKeepsExistingDrafts(t *testing.T) {
 	robot := CleanSetup()
 
-	responseMessage := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		responseMessage = append(responseMessage, msg.Text...)
-		return nil
+	uuids := []string{"first", "second"}
+	uuid.GenerateUUID = func() string {
+		next := uuids[0]
+		uuids = uuids[1:]
+		return next
+	}
+
+	testMsg := Message{Text: "bananas", User: "Balony2", Channel: "coffee3"}
+	testCaptures := []string{"", "feedback"}
+
+	for range []int{1, 2} {
+		if err := createPoll(&robot, &testMsg, testCaptures); err != nil {
+			t.Fatal("Was not expecting error to be thrown", err)
+		}
+	}
+
+	drafts, _ := FindDraftsByMessage(&testMsg)
+	if len(drafts) != 2 {
+		t.Fatal("Expected both polls to be kept as drafts but got: ", len(drafts))
+	}
+
+	current, _ := FindFirstInactivePollByMessage(&testMsg)
+	if current.UUID != "second" {
+		t.Error("Expected the newest poll to be the one being created but got: ", current.UUID)
 	}
+}
+
+func TestGetQuestion(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
 
 	var testTable = []struct {
 		InputMessage     Message
@@ -217,14 +359,14 @@ func TestGetQuestion(t *testing.T) {
 		// Response poll should save the question and transition to getAnswers
 		{
 			InputMessage:     Message{Text: "aww yiss", User: "1", Channel: "a"},
-			InputPoll:        Poll{Kind: "response", Creator: "1", Channel: "a", UUID: "a"},
+			InputPoll:        Poll{Kind: "response", Creator: "1", Channel: "a", UUID: "a", Stage: "getQuestion"},
 			ExpectedPoll:     Poll{Kind: "response", Creator: "1", Channel: "a", Stage: "getAnswers"},
 			ExpectedResponse: []byte("What are the possible responses (comma separated)?"),
 		},
 		//	Feedback poll should save the question and transition to getRecipients
 		{
 			InputMessage:     Message{Text: "aww yiss", User: "1", Channel: "b"},
-			InputPoll:        Poll{Kind: FeedbackPoll, Creator: "1", Channel: "b", UUID: "b"},
+			InputPoll:        Poll{Kind: FeedbackPoll, Creator: "1", Channel: "b", UUID: "b", Stage: "getQuestion"},
 			ExpectedPoll:     Poll{Kind: FeedbackPoll, Creator: "1", Channel: "b", Stage: "getRecipients"},
 			ExpectedResponse: []byte("Who should we send this to?"),
 		},
@@ -233,7 +375,7 @@ func TestGetQuestion(t *testing.T) {
 	msg := Message{Text: "Incoming question"}
 
 	for _, test := range testTable {
-		responseMessage = []byte("")
+		memory.Reset()
 
 		getQuestion(&robot, &msg, &test.InputPoll)
 
@@ -246,8 +388,8 @@ func TestGetQuestion(t *testing.T) {
 		if output.Stage != exp.Stage {
 			t.Fatal("Expected stage to be:", exp.Stage, "got:", output.Stage)
 		}
-		if bytes.Compare(responseMessage, test.ExpectedResponse) != 0 {
-			t.Fatal("Expected response to be: ", string(test.ExpectedResponse), " got: ", string(responseMessage))
+		if memory.SentText() != string(test.ExpectedResponse) {
+			t.Fatal("Expected response to be: ", string(test.ExpectedResponse), " got: ", memory.SentText())
 		}
 	}
 }
@@ -255,29 +397,23 @@ func TestGetQuestion(t *testing.T) {
 func TestGetRecipients(t *testing.T) {
 	robot := CleanSetup()
 
-	outgoing := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
-	}
-
 	var testTable = []struct {
 		TestPoll               Poll
 		RecipientsMsg          string
 		ExpectedResponsesCount int
 	}{
 		{
-			TestPoll:               Poll{Kind: "response", UUID: "1"},
+			TestPoll:               Poll{Kind: "response", UUID: "1", Stage: "getRecipients"},
 			RecipientsMsg:          "<@U1231231>",
 			ExpectedResponsesCount: 1,
 		},
 		{
-			TestPoll:               Poll{Kind: "response", UUID: "2"},
+			TestPoll:               Poll{Kind: "response", UUID: "2", Stage: "getRecipients"},
 			RecipientsMsg:          "<@U1231231>, <@U1231256>",
 			ExpectedResponsesCount: 2,
 		},
 		{
-			TestPoll:               Poll{Kind: "response", UUID: "3"},
+			TestPoll:               Poll{Kind: "response", UUID: "3", Stage: "getRecipients"},
 			RecipientsMsg:          "here are the recipients: <@U1231231>, <@U1231256>",
 			ExpectedResponsesCount: 2,
 		},
@@ -298,12 +434,7 @@ func TestGetRecipients(t *testing.T) {
 
 func TestSendPoll(t *testing.T) {
 	robot := CleanSetup()
-
-	outgoing := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
-	}
+	memory := robot.Transport.(*MemoryTransport)
 
 	var testTable = []struct {
 		InputPoll           Poll
@@ -311,7 +442,7 @@ func TestSendPoll(t *testing.T) {
 		ExpectedMessage     []byte
 		ExpectedPostMessage string
 		ExpectedStage       string
-		ExpectedRequests    int
+		ExpectedPosts       int
 	}{
 		// Test we don't send the poll unless we send yes
 		{
@@ -320,109 +451,212 @@ func TestSendPoll(t *testing.T) {
 			ExpectedMessage:     []byte("Okay not going to send poll. You can cancel with `cancel poll 1`"),
 			ExpectedPostMessage: "",
 			ExpectedStage:       "",
-			ExpectedRequests:    0,
+			ExpectedPosts:       0,
 		},
 		// Test when reply with yes we transition poll to active and send message
 		// no recipients so no requests
 		{
-			InputPoll:           Poll{Kind: "response", UUID: "2", Creator: "derp", Channel: "dorp"},
+			InputPoll:           Poll{Kind: "response", UUID: "2", Creator: "derp", Channel: "dorp", Stage: "sendPoll"},
 			InputMessage:        Message{Text: "yes", User: "derp", Channel: "dorp"},
 			ExpectedMessage:     []byte("Poll is live you can check in by asking me to `show poll 2`"),
 			ExpectedPostMessage: "",
 			ExpectedStage:       "active",
-			ExpectedRequests:    0,
+			ExpectedPosts:       0,
 		},
 		{
 			// Test poll has a single recipient and should progress to the active state and try posting one message to
 			// the recipient
-			InputPoll:           Poll{Kind: "response", UUID: "3", Creator: "derp", Channel: "dorp", Recipients: []Recipient{Recipient{SlackID: "Ben", SlackName: "Oro"}}},
+			InputPoll:           Poll{Kind: "response", UUID: "3", Creator: "derp", Channel: "dorp", Stage: "sendPoll", Recipients: []Recipient{Recipient{SlackID: "Ben", SlackName: "Oro"}}},
 			InputMessage:        Message{Text: "yes", User: "derp", Channel: "dorp"},
 			ExpectedMessage:     []byte("Poll is live you can check in by asking me to `show poll 3`"),
 			ExpectedPostMessage: "",
 			ExpectedStage:       "active",
-			ExpectedRequests:    1,
+			ExpectedPosts:       1,
+		},
+		{
+			// Test recipients Slack refuses to deliver to are reported back to the creator and the rest still get
+			// the poll
+			InputPoll:           Poll{Kind: "response", UUID: "4", Creator: "derp", Channel: "durp", Stage: "sendPoll", Recipients: []Recipient{Recipient{SlackID: "Ben"}, Recipient{SlackID: "UGONE"}}},
+			InputMessage:        Message{Text: "yes", User: "derp", Channel: "durp"},
+			ExpectedMessage:     []byte("Poll is live you can check in by asking me to `show poll 4`\nI couldn't deliver the poll to 1 of 2 recipients: <@UGONE> (channel_not_found)"),
+			ExpectedPostMessage: "",
+			ExpectedStage:       "active",
+			ExpectedPosts:       1,
 		},
 	}
 
+	memory.Unreachable = map[string]error{"UGONE": errors.New("channel_not_found")}
 	for _, testCase := range testTable {
-		outgoing = []byte("")
+		memory.Reset()
 		sendPoll(&robot, &testCase.InputMessage, &testCase.InputPoll)
 
 		resultPoll := &Poll{}
 		GetDB().Where("creator = ? AND channel = ?", testCase.InputMessage.User, testCase.InputMessage.Channel).First(&resultPoll)
 
-		if bytes.Compare(outgoing, testCase.ExpectedMessage) != 0 {
-			t.Fatal("Expected response message: ", string(testCase.ExpectedMessage), " got: ", string(outgoing))
+		if memory.SentText() != string(testCase.ExpectedMessage) {
+			t.Fatal("Expected response message: ", string(testCase.ExpectedMessage), " got: ", memory.SentText())
 		}
 
 		if resultPoll.Stage != testCase.ExpectedStage {
 			t.Fatal("Expected poll to be in stage: ", testCase.ExpectedStage, " got: ", resultPoll.Stage)
 		}
 
-		client := robot.Client.(*MockHTTPClient)
-		if testCase.ExpectedRequests != len(client.Requests) {
-			t.Fatalf("Expected requests: %d got: %d for %v", testCase.ExpectedRequests, len(client.Requests), resultPoll)
+		if posts := memory.Posts(); testCase.ExpectedPosts != len(posts) {
+			t.Fatalf("Expected posts: %d got: %d for %v", testCase.ExpectedPosts, len(posts), resultPoll)
 		}
 	}
 }
 
-func TestCancelPoll(t *testing.T) {
-	SetupTestDatabase()
+func TestSendPollWaitsToBeSentAgainWhenTheChannelPostFails(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	memory.Unreachable = map[string]error{"CTEAM": errors.New("not_in_channel")}
+
+	poll := &Poll{Kind: FeedbackPoll, UUID: "team", Creator: "UBOSS", Channel: "DBOSS", Stage: StageSendPoll, PreviousStage: StageGetCloseTime, Delivery: ChannelDelivery, PostChannel: "CTEAM"}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sendPoll(&robot, &Message{Text: "yes", User: "UBOSS", Channel: "DBOSS"}, poll); err == nil {
+		t.Error("Expected the failed post to be reported")
+	}
+
+	saved := &Poll{}
+	GetDB().Where("uuid = ?", "team").First(saved)
+	if saved.Stage != StageSendPoll || poll.Stage != StageSendPoll {
+		t.Fatal("Expected the poll to be waiting to be sent again got: ", saved.Stage, poll.Stage)
+	}
+
+	delete(memory.Unreachable, "CTEAM")
+	memory.Reset()
+	sendPoll(&robot, &Message{Text: "yes", User: "UBOSS", Channel: "DBOSS"}, poll)
 
-	outgoing := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
+	if poll.Stage != StageActive || poll.PostTS == "" || len(memory.Posts()) != 1 {
+		t.Error("Expected the second try to post the poll got: ", poll.Stage, poll.PostTS, memory.Posts())
 	}
+}
+
+func TestCancelPoll(t *testing.T) {
+	SetupTestDatabase()
 
 	var testTable = []struct {
 		TargetPoll       Poll
 		TargetMessage    Message
 		ExpectedResponse []byte
-		ExpectDeleted    bool
+		ExpectedStage    string
 	}{
 		{
-			TargetPoll:       Poll{Kind: "response", UUID: "1"},
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "blarg", Stage: "getQuestion"},
+			ExpectedResponse: []byte("Okay, cancelling the poll for you"),
+			ExpectedStage:    "cancelled",
+			TargetMessage:    Message{User: "blarg", Channel: "Wootzone", Text: "cancel poll 1", DirectMention: true},
+		},
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "blarg", Stage: "active"},
 			ExpectedResponse: []byte("Okay, cancelling the poll for you"),
-			ExpectDeleted:    true,
+			ExpectedStage:    "cancelled",
+			TargetMessage:    Message{User: "blarg", Channel: "Wootzone", Text: "cancel poll 1", DirectMention: true},
+		},
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "someone_else", Stage: "active"},
+			ExpectedResponse: []byte("Sorry, only the person who created the poll can cancel it"),
+			ExpectedStage:    "active",
+			TargetMessage:    Message{User: "blarg", Channel: "Wootzone", Text: "cancel poll 1", DirectMention: true},
+		},
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "blarg", Stage: "closed"},
+			ExpectedResponse: []byte("Poll 1 is closed and can no longer be cancelled"),
+			ExpectedStage:    "closed",
 			TargetMessage:    Message{User: "blarg", Channel: "Wootzone", Text: "cancel poll 1", DirectMention: true},
 		},
 		{
 			TargetPoll:       Poll{Kind: "response", UUID: "not_going_to_find"},
 			ExpectedResponse: []byte("Oops, couldn't find the poll for you"),
-			ExpectDeleted:    false,
 			TargetMessage:    Message{User: "blarg", Channel: "Wootzone", Text: "cancel poll 2", DirectMention: true},
 		},
 	}
 
 	for _, testEntry := range testTable {
 		robot := CleanSetup()
+		memory := robot.Transport.(*MemoryTransport)
 		GetDB().Save(&testEntry.TargetPoll)
 
-		outgoing = []byte("")
+		memory.Reset()
 		robot.Dispatch(&testEntry.TargetMessage)
 
-		if bytes.Compare(outgoing, testEntry.ExpectedResponse) != 0 {
-			t.Error("Got unexpected robot response: '", string(outgoing), "' expected: '", string(testEntry.ExpectedResponse), "'")
+		if memory.SentText() != string(testEntry.ExpectedResponse) {
+			t.Error("Got unexpected robot response: '", memory.SentText(), "' expected: '", string(testEntry.ExpectedResponse), "'")
 		}
 
-		if testEntry.ExpectDeleted == true {
-			poll, _ := FindFirstPreActivePollByName(testEntry.TargetPoll.UUID)
-			if poll.DeletedAt != nil {
-				t.Error("Expected poll to be deleted")
+		if testEntry.ExpectedStage != "" {
+			poll := &Poll{}
+			GetDB().Where("uuid = ?", testEntry.TargetPoll.UUID).First(poll)
+			if poll.ID == 0 {
+				t.Fatal("Expected poll to be kept around instead of deleted")
+			}
+
+			if poll.Stage != testEntry.ExpectedStage {
+				t.Error("Expected poll to be in stage", testEntry.ExpectedStage, "got", poll.Stage)
 			}
 		}
 	}
 }
 
-func TestAnswerPollSavesResponse(t *testing.T) {
-	robot := CleanSetup()
+func TestArchivePoll(t *testing.T) {
+	SetupTestDatabase()
+
+	var testTable = []struct {
+		TargetPoll       Poll
+		ExpectedResponse []byte
+		ExpectedStage    string
+	}{
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "blarg", Channel: "Wootzone", Stage: "closed"},
+			ExpectedResponse: []byte("Okay, archived poll 1. You can still find it with `list archived polls`"),
+			ExpectedStage:    "archived",
+		},
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "blarg", Channel: "Wootzone", Stage: "getAnswers"},
+			ExpectedResponse: []byte("Poll 1 is getAnswers, only active or closed polls can be archived"),
+			ExpectedStage:    "getAnswers",
+		},
+		{
+			TargetPoll:       Poll{Kind: "response", UUID: "1", Creator: "someone_else", Channel: "Wootzone", Stage: "closed"},
+			ExpectedResponse: []byte("Sorry, only the person who created the poll can archive it"),
+			ExpectedStage:    "closed",
+		},
+	}
+
+	for _, testEntry := range testTable {
+		robot := CleanSetup()
+		memory := robot.Transport.(*MemoryTransport)
+		GetDB().Save(&testEntry.TargetPoll)
+
+		memory.Reset()
+		robot.Dispatch(&Message{User: "blarg", Channel: "Wootzone", Text: "archive poll 1", DirectMention: true})
+
+		if memory.SentText() != string(testEntry.ExpectedResponse) {
+			t.Error("Got unexpected robot response: '", memory.SentText(), "' expected: '", string(testEntry.ExpectedResponse), "'")
+		}
+
+		poll := &Poll{}
+		GetDB().Where("uuid = ?", testEntry.TargetPoll.UUID).First(poll)
+		if poll.Stage != testEntry.ExpectedStage {
+			t.Error("Expected poll to be in stage", testEntry.ExpectedStage, "got", poll.Stage)
+		}
+	}
 
-	outgoing := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
+	// Archived polls still show their results
+	robot := CleanSetup()
+	GetDB().Save(&Poll{Kind: "response", UUID: "1", Creator: "blarg", Channel: "Wootzone", Stage: "archived"})
+	if err := showPoll(&robot, &Message{User: "blarg", Channel: "Wootzone"}, []string{"", "1"}); err != nil {
+		t.Fatal("Expected to be able to show an archived poll but got", err)
 	}
+}
+
+func TestAnswerPollSavesResponse(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
 
 	var testCases = []struct {
 		InputPoll            Poll
@@ -450,10 +684,28 @@ func TestAnswerPollSavesResponse(t *testing.T) {
 			ExpectedResponse:     PollResponse{SlackID: "1234", Value: "Why do we not get ice cream on thursdays?"},
 			ExpectedRobotMessage: []byte("Sorry about this but didn't not find a poll with the name test-2"),
 		},
+		// Poll has closed so the response is turned away
+		{
+			InputPoll:            Poll{Kind: "response", UUID: "3", Channel: "dorp", Creator: "Merv", Stage: "closed", PossibleAnswers: []PossibleAnswer{{Value: "yes"}}, Recipients: []Recipient{{SlackID: "1234"}}},
+			InputMsg:             Message{User: "1234", Channel: "Private_Channel"},
+			InputCaptures:        []string{"everything", "3", "yes"},
+			ExpectedSuccess:      false,
+			ExpectedResponse:     PollResponse{SlackID: "1234", Value: "yes"},
+			ExpectedRobotMessage: []byte("Sorry, that poll has closed and is no longer accepting answers"),
+		},
+		// Answering the first poll again changes the answer
+		{
+			InputPoll:            Poll{Kind: "feedback", UUID: "4", Channel: "dorp", Creator: "Merv", Stage: "active", Recipients: []Recipient{{SlackID: "1234"}}, Responses: []PollResponse{{SlackID: "1234", Value: "I love thursdays"}}},
+			InputMsg:             Message{User: "1234", Channel: "Private_Channel"},
+			InputCaptures:        []string{"everything", "4", "I hate thursdays"},
+			ExpectedSuccess:      true,
+			ExpectedResponse:     PollResponse{SlackID: "1234", Value: "I hate thursdays"},
+			ExpectedRobotMessage: []byte("Thanks, I've updated your answer from I love thursdays to I hate thursdays"),
+		},
 	}
 
 	for _, testCase := range testCases {
-		outgoing = []byte("")
+		memory.Reset()
 		GetDB().Save(&testCase.InputPoll)
 
 		answerPoll(&robot, &testCase.InputMsg, testCase.InputCaptures)
@@ -466,14 +718,15 @@ func TestAnswerPollSavesResponse(t *testing.T) {
 			t.Error("Expected recipient response: ", ExpectedResponse.Value, " but got: ", resultResponse.Value)
 		}
 
-		if bytes.Compare(outgoing, testCase.ExpectedRobotMessage) != 0 {
-			t.Error("Expected the robot to say: ", string(testCase.ExpectedRobotMessage), " but got: ", string(outgoing))
+		if memory.SentText() != string(testCase.ExpectedRobotMessage) {
+			t.Error("Expected the robot to say: ", string(testCase.ExpectedRobotMessage), " but got: ", memory.SentText())
 		}
 	}
 }
 
 func TestConversationFlowForResponsePoll(t *testing.T) {
 	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
 	testMessage := Message{
 		User:          "bloop",
 		Channel:       "blarg",
@@ -481,12 +734,6 @@ func TestConversationFlowForResponsePoll(t *testing.T) {
 		DirectMention: true,
 	}
 
-	outgoing := []byte{}
-	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
-		outgoing = append(outgoing, msg.Text...)
-		return nil
-	}
-
 	uuid.GenerateUUID = func() string {
 		return "blah"
 	}
@@ -504,7 +751,7 @@ func TestConversationFlowForResponsePoll(t *testing.T) {
 			NextMsg:       "create response poll",
 		},
 		{
-			ExpectedStage: "initial",
+			ExpectedStage: "getQuestion",
 			ExpectedText:  []byte("Creating a response poll. You can cancel the poll any time with `cancel poll blah`\nWhat was the question you wanted to ask?"),
 			NextMsg:       "Here is your question",
 		},
@@ -518,6 +765,11 @@ func TestConversationFlowForResponsePoll(t *testing.T) {
 			ExpectedText:  []byte("Who should we send this to?"),
 			NextMsg:       "<@U123>,<@U12415>",
 		},
+		{
+			ExpectedStage: "getCloseTime",
+			ExpectedText:  []byte("When should the poll close? You can say something like `2h`, `3d`, `2017-01-31 17:00` or `never`"),
+			NextMsg:       "never",
+		},
 		{
 			ExpectedStage:  "sendPoll",
 			ExpectedText:   []byte(""),
@@ -532,12 +784,353 @@ func TestConversationFlowForResponsePoll(t *testing.T) {
 			t.Fatal("Expected stage:", testStage.ExpectedStage, "got:", poll.Stage)
 		}
 
-		if bytes.Compare(outgoing, testStage.ExpectedText) != 0 {
-			t.Fatal("Expected output messages: ", string(testStage.ExpectedText), "got: ", string(outgoing))
+		if memory.SentText() != string(testStage.ExpectedText) {
+			t.Fatal("Expected output messages: ", string(testStage.ExpectedText), "got: ", memory.SentText())
 		}
 
-		outgoing = []byte("")
+		memory.Reset()
+		testMessage.Text = testStage.NextMsg
+		robot.Dispatch(&testMessage)
+	}
+}
+
+func TestEditPollDuringCreation(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	testMessage := Message{
+		User:          "bloop",
+		Channel:       "blarg",
+		Text:          "",
+		DirectMention: true,
+	}
+
+	uuid.GenerateUUID = func() string {
+		return "blah"
+	}
+
+	for _, text := range []string{"create response poll", "Whats the questoin", "a, b", "<@U123>", "never"} {
+		testMessage.Text = text
+		robot.Dispatch(&testMessage)
+	}
+
+	var testMessages = []struct {
+		NextMsg       string
+		ExpectedStage string
+		ExpectedText  []byte
+	}{
+		{
+			NextMsg:       "edit question",
+			ExpectedStage: StageGetQuestion,
+			ExpectedText:  []byte("What was the question you wanted to ask?"),
+		},
+		{
+			// The preview is posted again rather than asking for the answers
+			NextMsg:       "Whats the question",
+			ExpectedStage: StageSendPoll,
+			ExpectedText:  []byte(""),
+		},
+		{
+			NextMsg:       "edit answers",
+			ExpectedStage: StageGetAnswers,
+			ExpectedText:  []byte("What are the possible responses (comma separated)?"),
+		},
+		{
+			NextMsg:       "x, y, z",
+			ExpectedStage: StageSendPoll,
+			ExpectedText:  []byte(""),
+		},
+		{
+			NextMsg:       "back",
+			ExpectedStage: StageGetCloseTime,
+			ExpectedText:  []byte(closeTimePrompt),
+		},
+		{
+			NextMsg:       "back",
+			ExpectedStage: StageGetRecipients,
+			ExpectedText:  []byte("Who should we send this to?"),
+		},
+		{
+			// Going back a step carries on from where the creator went back to
+			NextMsg:       "<@U456>",
+			ExpectedStage: StageGetCloseTime,
+			ExpectedText:  []byte(closeTimePrompt),
+		},
+		{
+			NextMsg:       "2h",
+			ExpectedStage: StageSendPoll,
+			ExpectedText:  []byte(""),
+		},
+	}
+
+	for _, testStage := range testMessages {
+		memory.Reset()
 		testMessage.Text = testStage.NextMsg
 		robot.Dispatch(&testMessage)
+
+		poll, _ := FindFirstInactivePollByMessage(&testMessage)
+		if poll.Stage != testStage.ExpectedStage {
+			t.Fatal("After", testStage.NextMsg, "expected stage:", testStage.ExpectedStage, "got:", poll.Stage)
+		}
+
+		if memory.SentText() != string(testStage.ExpectedText) {
+			t.Fatal("After", testStage.NextMsg, "expected output messages: ", string(testStage.ExpectedText), "got: ", memory.SentText())
+		}
+	}
+
+	poll, _ := FindFirstInactivePollByMessage(&testMessage)
+	if poll.Question != "Whats the question" {
+		t.Error("Expected the question to be edited but got: ", poll.Question)
+	}
+
+	answers, _ := poll.GetAnswers()
+	if len(answers) != 3 {
+		t.Error("Expected the answers to be replaced with 3 answers but got: ", len(answers))
+	}
+
+	recipients, _ := poll.GetRecipients()
+	if len(recipients) != 1 || recipients[0].SlackID != "U456" {
+		t.Error("Expected the recipients to be replaced with U456 but got: ", recipients)
+	}
+
+	if poll.ClosesAt == nil {
+		t.Error("Expected the close time to be set again")
+	}
+}
+
+func TestEditPollRejectsStagesNotReached(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	testMessage := Message{
+		User:          "bloop",
+		Channel:       "blarg",
+		Text:          "create feedback poll",
+		DirectMention: true,
+	}
+
+	uuid.GenerateUUID = func() string {
+		return "blah"
+	}
+	robot.Dispatch(&testMessage)
+
+	var testMessages = []struct {
+		NextMsg      string
+		ExpectedText string
+	}{
+		{
+			NextMsg:      "edit recipients",
+			ExpectedText: "You haven't got to that part of the poll yet",
+		},
+		{
+			NextMsg:      "back",
+			ExpectedText: "There is nothing to go back to. You can cancel the poll with `cancel poll blah`",
+		},
+		{
+			NextMsg:      "edit answers",
+			ExpectedText: "There is nothing to go back to. You can cancel the poll with `cancel poll blah`",
+		},
+	}
+
+	for _, testCase := range testMessages {
+		memory.Reset()
+		testMessage.Text = testCase.NextMsg
+		robot.Dispatch(&testMessage)
+
+		if memory.SentText() != testCase.ExpectedText {
+			t.Error("After", testCase.NextMsg, "expected: ", testCase.ExpectedText, " but got: ", memory.SentText())
+		}
+
+		poll, _ := FindFirstInactivePollByMessage(&testMessage)
+		if poll.Stage != StageGetQuestion {
+			t.Error("Expected the poll to stay in getQuestion but got: ", poll.Stage)
+		}
+	}
+}
+
+func TestGetRecipientsReportsUnresolvedMentions(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	poll := &Poll{Kind: FeedbackPoll, UUID: "who", Creator: "bloop", Channel: "DBLOOP", Stage: StageGetRecipients, PreviousStage: StageGetQuestion}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{User: "bloop", Channel: "DBLOOP", Text: "<@U123> #nowhere"}
+	if err := getRecipients(&robot, msg, poll); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "I couldn't find #nowhere. Make sure I'm in any channels you mention. Who should we send this to?"
+	if memory.SentText() != expected {
+		t.Error("Expected: ", expected, " but got: ", memory.SentText())
+	}
+
+	if poll.Stage != StageGetRecipients {
+		t.Error("Expected the poll to keep waiting on recipients but got: ", poll.Stage)
+	}
+
+	if recipients, _ := poll.GetRecipients(); len(recipients) != 0 {
+		t.Error("Expected no recipients to be set but got: ", recipients)
+	}
+}
+
+func TestResolveRecipientsExclusions(t *testing.T) {
+	robot := Robot{
+		ID: "UCARLOS",
+		Channels: map[string]Channel{
+			"C1ENG": Channel{Name: "engineering", Members: []string{"UALICE", "UBOB", "UCAROL", "UCREATOR", "UBOT", "UGONE", "UCARLOS"}},
+		},
+		Users: map[string]User{
+			"UBOT":  User{SlackID: "UBOT", IsBot: true},
+			"UGONE": User{SlackID: "UGONE", Deleted: true},
+		},
+	}
+
+	var testCases = []struct {
+		Text               string
+		Expected           []string
+		ExpectedUnresolved []string
+	}{
+		{
+			// Bots, deactivated accounts, Carlos and the creator are left out of channels
+			Text:     "<#C1ENG|engineering>",
+			Expected: []string{"UALICE", "UBOB", "UCAROL"},
+		},
+		{
+			Text:     "#engineering except <@UALICE> <@UBOB>",
+			Expected: []string{"UCAROL"},
+		},
+		{
+			// The creator can still send the poll to themselves
+			Text:     "<@UCREATOR> <@UCAROL> EXCEPT <@UCAROL>",
+			Expected: []string{"UCREATOR"},
+		},
+		{
+			Text:               "#engineering except #nowhere",
+			Expected:           []string{"UALICE", "UBOB", "UCAROL"},
+			ExpectedUnresolved: []string{"#nowhere"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		recipients, unresolved := resolveRecipients(&robot, Message{Text: testCase.Text}, "UCREATOR")
+
+		result := []string{}
+		for _, recipient := range recipients {
+			result = append(result, recipient.SlackID)
+		}
+		sort.Strings(result)
+
+		if strings.Join(result, ",") != strings.Join(testCase.Expected, ",") {
+			t.Error("Expected", testCase.Text, "to resolve to", testCase.Expected, "but got:", result)
+		}
+
+		if strings.Join(unresolved, ",") != strings.Join(testCase.ExpectedUnresolved, ",") {
+			t.Error("Expected", testCase.Text, "to leave", testCase.ExpectedUnresolved, "unresolved but got:", unresolved)
+		}
+	}
+}
+
+func TestChannelPollIsPostedOnceAndAnsweredInThread(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	robot.Channels = map[string]Channel{"CTEAM": Channel{Name: "team", Members: []string{"U123", "U456", "UBOSS"}}}
+
+	poll := &Poll{Kind: ResponsePoll, UUID: "team-lunch", Creator: "UBOSS", Channel: "DBOSS", Stage: StageGetRecipients, PreviousStage: StageGetAnswers, Delivery: ChannelDelivery, PossibleAnswers: []PossibleAnswer{{Value: "pizza"}, {Value: "tacos"}}}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	getRecipients(&robot, &Message{User: "UBOSS", Channel: "DBOSS", Text: "#team except <@U456>"}, poll)
+	if poll.PostChannel != "CTEAM" || poll.Stage != StageGetCloseTime {
+		t.Fatal("Expected the poll to be posted in CTEAM got: ", poll.PostChannel, poll.Stage)
+	}
+
+	if recipients, _ := poll.GetRecipients(); len(recipients) != 1 || recipients[0].SlackID != "U123" {
+		t.Error("Expected only U123 to be able to answer got: ", recipients)
+	}
+
+	poll.TransitionTo(StageSendPoll, "UBOSS")
+	poll.TransitionTo(StageActive, "UBOSS")
+	memory.Reset()
+	if _, err := deliverPoll(&robot, poll); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := memory.Posts()
+	if len(posts) != 1 || posts[0].Channel != "CTEAM" {
+		t.Fatal("Expected the poll to be posted once in the channel got: ", posts)
+	}
+
+	if poll.PostTS != posts[0].TS {
+		t.Error("Expected the posted message to be remembered got: ", poll.PostTS)
+	}
+
+	robot.Dispatch(&Message{User: "U123", Channel: "CTEAM", ThreadTS: poll.PostTS, Text: "burgers"})
+	if replies := memory.Sent(); len(replies) != 1 || replies[0].ThreadTS != poll.PostTS {
+		t.Fatal("Expected a bad answer to be replied to in the thread got: ", replies)
+	}
+
+	robot.Dispatch(&Message{User: "U123", Channel: "CTEAM", ThreadTS: poll.PostTS, Text: "tacos"})
+	if current := poll.CurrentResponse("U123"); current.Value != "tacos" {
+		t.Error("Expected the threaded reply to answer the poll got: ", current.Value)
+	}
+
+	updates := memory.Updates()
+	if len(updates) == 0 || updates[len(updates)-1].TS != poll.PostTS || updates[len(updates)-1].Channel != "CTEAM" {
+		t.Error("Expected the posted poll to be updated got: ", updates)
+	}
+}
+
+func TestChannelPollIsRemindedAndArchivedInPlace(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	poll := &Poll{Kind: FeedbackPoll, UUID: "retro", Creator: "UBOSS", Channel: "DBOSS", Stage: StageActive, Delivery: ChannelDelivery, PostChannel: "CTEAM", PostTS: "1.0", RemindEvery: time.Hour}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+	poll.SetRecipients([]Recipient{{SlackID: "U123"}, {SlackID: "U456"}})
+
+	sendDueReminders(&robot, time.Now().Add(2*time.Hour))
+
+	sent := memory.Sent()
+	if len(sent) != 1 || sent[0].Channel != "CTEAM" || sent[0].ThreadTS != "1.0" {
+		t.Fatal("Expected one reminder in the poll's thread got: ", sent)
+	}
+
+	if len(memory.Posts()) != 0 {
+		t.Error("Expected nobody to be sent a direct reminder got: ", memory.Posts())
+	}
+
+	memory.Reset()
+	archivePoll(&robot, &Message{User: "UBOSS", Channel: "DBOSS"}, []string{"", "retro"})
+
+	updates := memory.Updates()
+	if len(updates) != 1 || updates[0].TS != "1.0" || len(updates[0].Attachments[0].Actions) != 0 {
+		t.Error("Expected the posted poll to show it is archived got: ", updates)
+	}
+}
+
+func TestChannelPollNeedsExactlyOneChannel(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+	robot.Channels = map[string]Channel{
+		"CTEAM":  Channel{Name: "team", Members: []string{"U123"}},
+		"COTHER": Channel{Name: "other", Members: []string{"U456"}},
+	}
+
+	poll := &Poll{Kind: FeedbackPoll, UUID: "where", Creator: "UBOSS", Channel: "DBOSS", Stage: StageGetRecipients, PreviousStage: StageGetQuestion, Delivery: ChannelDelivery}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	getRecipients(&robot, &Message{User: "UBOSS", Channel: "DBOSS", Text: "#team #other"}, poll)
+	expected := "Mention exactly one channel to post the poll in. " + postChannelPrompt
+	if memory.SentText() != expected {
+		t.Error("Expected: ", expected, " but got: ", memory.SentText())
+	}
+
+	if poll.Stage != StageGetRecipients {
+		t.Error("Expected the poll to keep waiting for a channel got: ", poll.Stage)
 	}
 }
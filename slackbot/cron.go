@@ -0,0 +1,193 @@
+package slackbot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five field cron expression (minute hour day-of-month month day-of-week).
+// Each field supports `*`, single values, ranges `a-b`, steps `*/n` or `a-b/n` and comma separated lists.
+// Months and weekdays can also be given by their three letter names e.g `0 9 * * MON`.
+type CronSchedule struct {
+	Expression string
+
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+
+	// When both the day of month and day of week are restricted a time matches if either one matches.
+	// This mirrors how cron has always behaved
+	dayOfMonthStar bool
+	dayOfWeekStar  bool
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinute     = cronField{name: "minute", min: 0, max: 59}
+	cronHour       = cronField{name: "hour", min: 0, max: 23}
+	cronDayOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday can be either 0 or 7
+	cronDayOfWeek = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ParseCron parses a standard five field cron expression
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Expected 5 fields in cron expression but got %d", len(fields))
+	}
+
+	schedule := &CronSchedule{
+		Expression:     strings.Join(fields, " "),
+		dayOfMonthStar: fields[2] == "*",
+		dayOfWeekStar:  fields[4] == "*",
+	}
+
+	var err error
+	if schedule.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfMonth, err = cronDayOfMonth.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfWeek, err = cronDayOfWeek.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// Fold 7 back onto Sunday so we only have to check 0-6
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+	return schedule, nil
+}
+
+func (field cronField) value(text string) (int, error) {
+	if v, ok := field.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s value %s", field.name, text)
+	}
+
+	if v < field.min || v > field.max {
+		return 0, fmt.Errorf("The %s value %d must be between %d and %d", field.name, v, field.min, field.max)
+	}
+	return v, nil
+}
+
+func (field cronField) parse(text string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(text, ",") {
+		rangeText := part
+		step := 1
+
+		if i := strings.Index(part, "/"); i != -1 {
+			rangeText = part[:i]
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("Invalid step in %s field: %s", field.name, part)
+			}
+			step = s
+		}
+
+		start, end := field.min, field.max
+		switch {
+		case rangeText == "*":
+		case strings.Contains(rangeText, "-"):
+			bounds := strings.SplitN(rangeText, "-", 2)
+			var err error
+			if start, err = field.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = field.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("Invalid range in %s field: %s", field.name, rangeText)
+			}
+		default:
+			v, err := field.value(rangeText)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			// a value with a step such as 5/15 runs from the value to the end of the range
+			if step == 1 {
+				end = v
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (schedule *CronSchedule) matchesDay(t time.Time) bool {
+	domMatch := schedule.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := schedule.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if schedule.dayOfMonthStar || schedule.dayOfWeekStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first time after t which matches the schedule. A zero time is returned when no
+// matching time exists e.g `0 0 30 2 *`
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Leap days mean we might need to search a few years ahead before giving up. Century years which aren't
+	// leap years such as 2100 leave an eight year gap between leap days
+	limit := t.AddDate(8, 0, 0)
+	for t.Before(limit) {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
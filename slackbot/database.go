@@ -18,11 +18,40 @@ func Migrate() {
 		&PossibleAnswer{},
 		&Recipient{},
 		&PollResponse{},
+		&ResponseSelection{},
+		&PollSchedule{},
+		&PollTransition{},
+		&Survey{},
+		&SurveyProgress{},
+		&FollowUpQuestion{},
+		&FollowUpResponse{},
+		&PollTemplate{},
+		&DraftSelection{},
 	).Error
 
 	if err != nil {
 		logrus.Error(err)
 	}
+
+	// A user gets one answer per poll. Changed answers are soft deleted and anonymous answers have no user
+	// so neither of those count towards the limit
+	err = GetDB().Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_poll_responses_one_per_user
+		ON poll_responses (poll_id, slack_id)
+		WHERE slack_id <> '' AND deleted_at IS NULL`).Error
+
+	if err != nil {
+		logrus.Error(err)
+	}
+
+	// Drafts used to wait for their question in the initial stage. Copies of a poll pass through initial too
+	// but already have a question so they are left alone
+	err = GetDB().Exec(`UPDATE polls SET previous_stage = stage, stage = ?
+		WHERE stage = ? AND (question = '' OR question IS NULL) AND deleted_at IS NULL`,
+		StageGetQuestion, StageInitial).Error
+
+	if err != nil {
+		logrus.Error(err)
+	}
 }
 
 // DropDatabaseTables drops all the database tables cold turkey
@@ -34,6 +63,15 @@ func DropDatabaseTables() {
 		&PossibleAnswer{},
 		&Recipient{},
 		&PollResponse{},
+		&ResponseSelection{},
+		&PollSchedule{},
+		&PollTransition{},
+		&Survey{},
+		&SurveyProgress{},
+		&FollowUpQuestion{},
+		&FollowUpResponse{},
+		&PollTemplate{},
+		&DraftSelection{},
 	).Error
 
 	if err != nil {
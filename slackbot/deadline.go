@@ -0,0 +1,94 @@
+package slackbot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var (
+	closerInterval = time.Minute
+
+	// Absolute close times we understand. They are read in server time
+	closeTimeLayouts = []string{
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+)
+
+// parseDuration understands go durations such as `90m` or `2h` as well as a number of days like `3d`
+func parseDuration(text string) (time.Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	if strings.HasSuffix(text, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(text, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("Unable to understand %s as a number of days", text)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(text)
+}
+
+// parseCloseTime turns the creators reply into a deadline. A nil time means the poll stays open until it is cancelled
+func parseCloseTime(text string, now time.Time) (*time.Time, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	switch text {
+	case "never", "no", "none":
+		return nil, nil
+	}
+
+	var closesAt time.Time
+	if duration, err := parseDuration(text); err == nil {
+		closesAt = now.Add(duration)
+	} else {
+		parsed := false
+		for _, layout := range closeTimeLayouts {
+			if closesAt, err = time.ParseInLocation(layout, text, now.Location()); err == nil {
+				parsed = true
+				break
+			}
+		}
+
+		if !parsed {
+			return nil, fmt.Errorf("Unable to understand %s as a close time", text)
+		}
+	}
+
+	if !closesAt.After(now) {
+		return nil, fmt.Errorf("The close time %s is in the past", closesAt.Format(time.RFC1123))
+	}
+	return &closesAt, nil
+}
+
+func closeExpiredPolls(robot *Robot, now time.Time) {
+	polls, err := FindExpiredPolls(now)
+	if err != nil {
+		logrus.Error("Unable to load expired polls: ", err)
+		return
+	}
+
+	for _, poll := range polls {
+		if err := poll.Close(robot); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"poll_id":   poll.ID,
+				"poll_uuid": poll.UUID,
+			}).Error("Error closing poll: ", err)
+		}
+	}
+}
+
+// RunPollCloser closes active polls once their deadline passes
+func RunPollCloser(robot *Robot) {
+	checkInterval := time.NewTicker(closerInterval)
+	for {
+		select {
+		case now := <-checkInterval.C:
+			closeExpiredPolls(robot, now)
+		}
+	}
+}
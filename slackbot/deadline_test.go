@@ -0,0 +1,100 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCloseTime(t *testing.T) {
+	now := time.Date(2017, time.January, 1, 12, 30, 0, 0, time.UTC)
+	inTwoHours := now.Add(2 * time.Hour)
+	inThreeDays := now.AddDate(0, 0, 3)
+	endOfMonth := time.Date(2017, time.January, 31, 17, 0, 0, 0, time.UTC)
+
+	var testCases = []struct {
+		Input         string
+		Expected      *time.Time
+		ExpectedError bool
+	}{
+		{Input: "never", Expected: nil},
+		{Input: "2h", Expected: &inTwoHours},
+		{Input: " 3d ", Expected: &inThreeDays},
+		{Input: "2017-01-31 17:00", Expected: &endOfMonth},
+		{Input: "2016-01-31", ExpectedError: true},
+		{Input: "next tuesday", ExpectedError: true},
+		{Input: "-2h", ExpectedError: true},
+	}
+
+	for _, testCase := range testCases {
+		result, err := parseCloseTime(testCase.Input, now)
+		if testCase.ExpectedError {
+			if err == nil {
+				t.Error("Expected error parsing close time: ", testCase.Input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Error("Was not expecting error parsing ", testCase.Input, ": ", err)
+			continue
+		}
+
+		if testCase.Expected == nil && result != nil {
+			t.Error("Expected no close time for ", testCase.Input, " got: ", result)
+		} else if testCase.Expected != nil && (result == nil || !result.Equal(*testCase.Expected)) {
+			t.Error("Expected close time: ", testCase.Expected, " got: ", result)
+		}
+	}
+}
+
+func TestCloseExpiredPolls(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	expired := Poll{Kind: ResponsePoll, UUID: "expired", Creator: "derp", Channel: "dorp", Stage: "active", ClosesAt: &past}
+	open := Poll{Kind: ResponsePoll, UUID: "open", Creator: "derp", Channel: "dorp", Stage: "active", ClosesAt: &future}
+	forever := Poll{Kind: ResponsePoll, UUID: "forever", Creator: "derp", Channel: "dorp", Stage: "active"}
+	for _, poll := range []*Poll{&expired, &open, &forever} {
+		GetDB().Save(poll)
+	}
+
+	closeExpiredPolls(&robot, now)
+
+	var testCases = []struct {
+		UUID          string
+		ExpectedStage string
+	}{
+		{UUID: "expired", ExpectedStage: "closed"},
+		{UUID: "open", ExpectedStage: "active"},
+		{UUID: "forever", ExpectedStage: "active"},
+	}
+
+	for _, testCase := range testCases {
+		poll, err := FindFirstPublishedPollByUUID(testCase.UUID)
+		if err != nil {
+			t.Fatal("Unable to find poll: ", testCase.UUID)
+		}
+
+		if poll.Stage != testCase.ExpectedStage {
+			t.Error("Expected poll ", testCase.UUID, " to be in stage: ", testCase.ExpectedStage, " got: ", poll.Stage)
+		}
+	}
+
+	posts := memory.Posts()
+	if len(posts) != 1 {
+		t.Fatal("Expected the final results to be posted once got: ", len(posts))
+	}
+
+	if text := posts[0].Text; !strings.Contains(text, "Poll expired has closed") {
+		t.Error("Expected results message for poll expired got: ", text)
+	}
+
+	if _, err := expired.AddResponse("U123", "yes"); err != ErrPollClosed {
+		t.Error("Expected closed poll to reject responses got: ", err)
+	}
+}
@@ -0,0 +1,60 @@
+package slackbot
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DraftSelection remembers which of the creator's drafts in a channel the conversation continues. Each new
+// draft is selected when it is started and `resume poll` switches back to an older one
+type DraftSelection struct {
+	gorm.Model
+	Creator string `gorm:"not null;unique_index:idx_draft_selection"`
+	Channel string `gorm:"not null;unique_index:idx_draft_selection"`
+	PollID  uint   `gorm:"not null"`
+}
+
+// SelectDraft makes the poll the one the creator's conversation in its channel continues
+func SelectDraft(poll *Poll) error {
+	selection := &DraftSelection{}
+	GetDB().Where("creator = ? AND channel = ?", poll.Creator, poll.Channel).First(selection)
+	selection.Creator = poll.Creator
+	selection.Channel = poll.Channel
+	selection.PollID = poll.ID
+	return GetDB().Save(selection).Error
+}
+
+// FindDraftsByMessage are the polls the user is still putting together in the channel, oldest first
+func FindDraftsByMessage(msg *Message) ([]Poll, error) {
+	drafts := []Poll{}
+	err := GetDB().
+		Where("creator = ? AND channel = ? AND stage IN (?)", msg.User, msg.Channel, preActiveStages).
+		Order("created_at, id").
+		Find(&drafts).Error
+	return drafts, err
+}
+
+// FindFirstInactivePollByMessage is the draft the user is working on in the channel. That is the one they
+// selected last, or their newest draft once the selected one has been sent or cancelled
+func FindFirstInactivePollByMessage(msg *Message) (*Poll, error) {
+	selection := &DraftSelection{}
+	GetDB().Where("creator = ? AND channel = ?", msg.User, msg.Channel).First(selection)
+
+	poll := &Poll{}
+	if selection.ID != 0 {
+		GetDB().Where("id = ? AND stage IN (?)", selection.PollID, preActiveStages).First(poll)
+	}
+
+	if poll.ID == 0 {
+		GetDB().
+			Where("creator = ? AND channel = ? AND stage IN (?)", msg.User, msg.Channel, preActiveStages).
+			Order("created_at desc, id desc").
+			First(poll)
+	}
+
+	if poll.ID == 0 {
+		return poll, fmt.Errorf("No poll found")
+	}
+	return poll, nil
+}
@@ -0,0 +1,68 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListAndResumeDrafts(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	drafts := []*Poll{
+		{Kind: ResponsePoll, UUID: "lunch", Creator: "chef", Channel: "DCHEF", Stage: StageGetAnswers, Question: "Where for lunch?"},
+		{Kind: FeedbackPoll, UUID: "retro", Creator: "chef", Channel: "DCHEF", Stage: StageGetQuestion},
+		{Kind: FeedbackPoll, UUID: "elsewhere", Creator: "chef", Channel: "DOTHER", Stage: StageGetQuestion},
+		{Kind: FeedbackPoll, UUID: "sent", Creator: "chef", Channel: "DCHEF", Stage: StageActive},
+	}
+	for _, draft := range drafts {
+		if err := draft.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	command := func(user, text string) string {
+		memory.Reset()
+		robot.Dispatch(&Message{User: user, Channel: "DCHEF", Text: text})
+		return strings.Join(memory.SentTexts(), "|")
+	}
+
+	// Without a selection the newest draft is carried on with
+	command("chef", "How did the sprint go?")
+	if poll, _ := FindFirstPreActivePollByName("retro"); poll.Question != "How did the sprint go?" {
+		t.Fatal("Expected the newest draft to get the question but got: ", poll.Question)
+	}
+
+	if output := command("chef", "resume poll lunch"); output != "Okay, carrying on with poll lunch|What are the possible responses (comma separated)?" {
+		t.Error("Unexpected response resuming a draft: ", output)
+	}
+
+	command("chef", "pizza, tacos")
+	if answers, _ := drafts[0].GetAnswers(); len(answers) != 2 {
+		t.Error("Expected the resumed draft to get the answers but got: ", answers)
+	}
+
+	var testCases = []struct {
+		User     string
+		Text     string
+		Expected string
+	}{
+		{"chef", "resume poll sent", "Poll sent is active, only polls being created can be resumed"},
+		{"chef", "resume poll elsewhere", "Poll elsewhere was started in another channel, resume it from there"},
+		{"someone_else", "resume poll retro", "Sorry, only the person who created the poll can resume it"},
+		{"someone_else", "list drafts", "You have no polls being created"},
+	}
+
+	for _, testCase := range testCases {
+		if output := command(testCase.User, testCase.Text); output != testCase.Expected {
+			t.Error("Expected", testCase.Text, "to reply", testCase.Expected, "but got: ", output)
+		}
+	}
+
+	command("chef", "list drafts")
+	posts := memory.Posts()
+	listed := attachmentsText(posts[len(posts)-1].Attachments)
+	if !strings.Contains(listed, "id:lunch (current)") || !strings.Contains(listed, "id:retro") || strings.Contains(listed, "id:sent") {
+		t.Error("Expected the drafts in the channel to be listed with the current one marked but got: ", listed)
+	}
+}
@@ -0,0 +1,175 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// RTMTransport receives messages over the websocket rtm.start hands out
+	RTMTransport = "rtm"
+
+	// EventsTransport receives messages Slack posts to /slack/events and sends everything over the Web API
+	EventsTransport = "events"
+
+	// seenEventsSize is how many event ids are remembered to spot Slack sending the same event twice. Retries
+	// come within minutes so only the most recent events need remembering
+	seenEventsSize = 1000
+)
+
+// seenEvents remembers the ids of the most recent events so a retried event isn't answered twice
+type seenEvents struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	order []string
+	size  int
+}
+
+func newSeenEvents(size int) *seenEvents {
+	return &seenEvents{ids: make(map[string]bool), size: size}
+}
+
+// seenBefore reports whether the id has been seen and remembers it if it hasn't, forgetting the oldest id once
+// there are more than size. Checking and remembering happen together so two deliveries of the same event
+// arriving at once can't both be let through
+func (seen *seenEvents) seenBefore(id string) bool {
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+
+	if id == "" {
+		return false
+	}
+	if seen.ids[id] {
+		return true
+	}
+	seen.ids[id] = true
+	seen.order = append(seen.order, id)
+
+	if len(seen.order) > seen.size {
+		delete(seen.ids, seen.order[0])
+		seen.order = seen.order[1:]
+	}
+	return false
+}
+
+// forget drops the id so the next time it is sent it's handled as a new event
+func (seen *seenEvents) forget(id string) {
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+
+	if !seen.ids[id] {
+		return
+	}
+	delete(seen.ids, id)
+	for i, seenID := range seen.order {
+		if seenID == id {
+			seen.order = append(seen.order[:i], seen.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// eventMessage turns an Events API event into the message the RTM would have sent us. Mentions in channels
+// arrive as app_mention events so plain channel messages are only wanted when they reply in a thread, which is
+// how people answer channel polls
+func (robot *Robot) eventMessage(event Message) (Message, bool) {
+	if event.Subtype != "" || event.BotID != "" || event.User == "" {
+		return event, false
+	}
+
+	switch event.Type {
+	case "app_mention":
+		event.Type = "message"
+		return event, true
+	case "message":
+		if event.ChannelType == "im" {
+			return event, true
+		}
+		return event, event.ThreadTS != "" && !strings.Contains(event.Text, robot.SlackIDString())
+	}
+	return event, false
+}
+
+// EventsHandler receives the events Slack posts to us when the events transport is used and passes the
+// messages on to the workers
+func (robot *Robot) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := robot.readVerifiedBody(r)
+	if err != nil {
+		logrus.Warn("Rejected events request: ", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	envelope := &EventEnvelope{}
+	if err := json.Unmarshal(body, envelope); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, envelope.Challenge)
+		return
+	case "event_callback":
+		retry := r.Header.Get("X-Slack-Retry-Num")
+		if (envelope.EventID == "" && retry != "") || robot.seenEvents.seenBefore(envelope.EventID) {
+			logrus.WithFields(logrus.Fields{
+				"event_id":     envelope.EventID,
+				"retry_num":    retry,
+				"retry_reason": r.Header.Get("X-Slack-Retry-Reason"),
+			}).Info("Ignoring an event we have already received")
+			break
+		}
+
+		// Slack retries events it doesn't get an answer to within a few seconds so the message is queued
+		// without waiting on a worker to be free. Events are queued in the order they arrive and when the
+		// queue is full Slack is told to try again later rather than the event being dropped
+		if msg, ok := robot.eventMessage(envelope.Event); ok {
+			select {
+			case robot.ListenChan <- msg:
+			default:
+				logrus.WithField("event_id", envelope.EventID).Warn("Too busy to queue the event, Slack will send it again")
+				robot.seenEvents.forget(envelope.EventID)
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func slackAuthTest(token string) (*ResponseAuthTest, error) {
+	resp, err := http.Get(fmt.Sprintf("https://slack.com/api/auth.test?token=%s", token))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var authResponse ResponseAuthTest
+	if err = json.Unmarshal(body, &authResponse); err != nil {
+		return nil, err
+	}
+
+	if !authResponse.Ok {
+		return nil, slackError("Slack authentication error", authResponse.Error)
+	}
+	return &authResponse, nil
+}
@@ -0,0 +1,166 @@
+package slackbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventsHandler(t *testing.T) {
+	robot := &Robot{ID: "UCARLOS", SigningSecret: "shhh", ListenChan: make(chan Message, 1), seenEvents: newSeenEvents(seenEventsSize)}
+
+	var testCases = []struct {
+		Body             string
+		Secret           string
+		ExpectedStatus   int
+		ExpectedResponse string
+		ExpectedMessage  *Message
+	}{
+		{
+			Body:             `{"type": "url_verification", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`,
+			Secret:           "shhh",
+			ExpectedStatus:   http.StatusOK,
+			ExpectedResponse: "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P",
+		},
+		{
+			Body:           `{"type": "url_verification", "challenge": "nope"}`,
+			Secret:         "wrong",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Body:            `{"type": "event_callback", "event": {"type": "message", "channel_type": "im", "channel": "D1", "user": "U1", "text": "create poll", "ts": "1.1"}}`,
+			Secret:          "shhh",
+			ExpectedStatus:  http.StatusOK,
+			ExpectedMessage: &Message{Type: "message", Channel: "D1", User: "U1", Text: "create poll"},
+		},
+		{
+			Body:            `{"type": "event_callback", "event": {"type": "app_mention", "channel": "C1", "user": "U1", "text": "<@UCARLOS> show poll", "ts": "1.2"}}`,
+			Secret:          "shhh",
+			ExpectedStatus:  http.StatusOK,
+			ExpectedMessage: &Message{Type: "message", Channel: "C1", User: "U1", Text: "<@UCARLOS> show poll"},
+		},
+		{
+			Body:            `{"type": "event_callback", "event": {"type": "message", "channel_type": "channel", "channel": "C1", "user": "U1", "text": "yes", "ts": "1.3", "thread_ts": "1.0"}}`,
+			Secret:          "shhh",
+			ExpectedStatus:  http.StatusOK,
+			ExpectedMessage: &Message{Type: "message", Channel: "C1", User: "U1", Text: "yes", ThreadTS: "1.0"},
+		},
+		{
+			Body:           `{"type": "event_callback", "event": {"type": "message", "channel_type": "channel", "channel": "C1", "user": "U1", "text": "<@UCARLOS> yes", "ts": "1.4", "thread_ts": "1.0"}}`,
+			Secret:         "shhh",
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Body:           `{"type": "event_callback", "event": {"type": "message", "channel_type": "channel", "channel": "C1", "user": "U1", "text": "lunch?", "ts": "1.5"}}`,
+			Secret:         "shhh",
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Body:           `{"type": "event_callback", "event": {"type": "message", "subtype": "bot_message", "channel_type": "im", "channel": "D1", "bot_id": "B1", "text": "hi", "ts": "1.6"}}`,
+			Secret:         "shhh",
+			ExpectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", "/slack/events", strings.NewReader(testCase.Body))
+		signRequest(req, testCase.Secret, testCase.Body, time.Now())
+		recorder := httptest.NewRecorder()
+
+		robot.EventsHandler(recorder, req)
+
+		if recorder.Code != testCase.ExpectedStatus {
+			t.Error("Expected status ", testCase.ExpectedStatus, " got: ", recorder.Code, " for ", testCase.Body)
+		}
+
+		if testCase.ExpectedResponse != "" && recorder.Body.String() != testCase.ExpectedResponse {
+			t.Error("Expected the challenge to be echoed back got: ", recorder.Body.String())
+		}
+
+		select {
+		case msg := <-robot.ListenChan:
+			expected := testCase.ExpectedMessage
+			if expected == nil {
+				t.Error("Was not expecting a message to be queued for ", testCase.Body)
+			} else if msg.Type != expected.Type || msg.Channel != expected.Channel || msg.User != expected.User || msg.Text != expected.Text || msg.ThreadTS != expected.ThreadTS {
+				t.Error("Expected message ", *expected, " got: ", msg)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if testCase.ExpectedMessage != nil {
+				t.Error("Expected a message to be queued for ", testCase.Body)
+			}
+		}
+	}
+}
+
+func TestEventsHandlerAnswersEachEventOnce(t *testing.T) {
+	robot := &Robot{ID: "UCARLOS", SigningSecret: "shhh", ListenChan: make(chan Message, 1), seenEvents: newSeenEvents(seenEventsSize)}
+
+	send := func(body, retry string) int {
+		req := httptest.NewRequest("POST", "/slack/events", strings.NewReader(body))
+		signRequest(req, "shhh", body, time.Now())
+		if retry != "" {
+			req.Header.Set("X-Slack-Retry-Num", retry)
+		}
+		recorder := httptest.NewRecorder()
+		robot.EventsHandler(recorder, req)
+		return recorder.Code
+	}
+
+	first := `{"type": "event_callback", "event_id": "Ev1", "event": {"type": "message", "channel_type": "im", "channel": "D1", "user": "U1", "text": "first", "ts": "1.1"}}`
+	second := `{"type": "event_callback", "event_id": "Ev2", "event": {"type": "message", "channel_type": "im", "channel": "D1", "user": "U1", "text": "second", "ts": "1.2"}}`
+
+	if code := send(first, ""); code != http.StatusOK {
+		t.Error("Expected the first event to be queued got: ", code)
+	}
+
+	if code := send(first, "1"); code != http.StatusOK || len(robot.ListenChan) != 1 {
+		t.Error("Expected the retried event to be acknowledged and ignored got: ", code, " queued: ", len(robot.ListenChan))
+	}
+
+	if code := send(second, ""); code != http.StatusServiceUnavailable {
+		t.Error("Expected Slack to be asked to try again while the queue is full got: ", code)
+	}
+
+	if msg := <-robot.ListenChan; msg.Text != "first" {
+		t.Error("Expected the first message got: ", msg.Text)
+	}
+
+	if code := send(second, "1"); code != http.StatusOK {
+		t.Error("Expected the retry of an event we turned away to be queued got: ", code)
+	}
+
+	if msg := <-robot.ListenChan; msg.Text != "second" {
+		t.Error("Expected the second message got: ", msg.Text)
+	}
+}
+
+func TestSeenBeforeLetsOneOfTheSameEventThrough(t *testing.T) {
+	seen := newSeenEvents(seenEventsSize)
+
+	var wg sync.WaitGroup
+	firsts := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !seen.seenBefore("Ev1") {
+				firsts <- true
+			}
+		}()
+	}
+	wg.Wait()
+	close(firsts)
+
+	if len(firsts) != 1 {
+		t.Error("Expected the event to be let through once got: ", len(firsts))
+	}
+
+	seen.forget("Ev1")
+	if seen.seenBefore("Ev1") {
+		t.Error("Expected a forgotten event to be let through again")
+	}
+}
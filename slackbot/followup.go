@@ -0,0 +1,201 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrUnknownAnswer = errors.New("CarlosTheCurious: Answer is not one of the possible answers of the poll")
+)
+
+// FollowUpQuestion is a free text question asked straight after someone gives a particular answer to a poll
+type FollowUpQuestion struct {
+	gorm.Model
+	PollID           uint   `gorm:"not null;index"`
+	PossibleAnswerID uint   `gorm:"not null"`
+	Question         string `gorm:"not null"`
+}
+
+// FollowUpResponse is the answer to a follow up question. It is created when the question is asked and stays
+// pending until AnsweredAt is set. It hangs off the answer which caused it to be asked so changing that
+// answer leaves the follow up behind with it
+type FollowUpResponse struct {
+	gorm.Model
+	FollowUpQuestionID uint `gorm:"not null"`
+	PollResponseID     uint `gorm:"not null;index"`
+	Value              string
+	AnsweredAt         *time.Time
+}
+
+// AddFollowUp asks the question of everyone who gives the answer. Each answer has at most one follow up so
+// adding another replaces the question
+func (poll *Poll) AddFollowUp(answer, question string) (*FollowUpQuestion, error) {
+	possible := PossibleAnswer{}
+	GetDB().Where("poll_id = ? AND value = ?", poll.ID, answer).First(&possible)
+	if possible.ID == 0 {
+		return nil, ErrUnknownAnswer
+	}
+
+	followUp := &FollowUpQuestion{}
+	GetDB().Where("poll_id = ? AND possible_answer_id = ?", poll.ID, possible.ID).First(followUp)
+	followUp.PollID = poll.ID
+	followUp.PossibleAnswerID = possible.ID
+	followUp.Question = question
+	return followUp, GetDB().Save(followUp).Error
+}
+
+// FollowUps returns the follow up questions of the poll along with the answer each one is for
+func (poll *Poll) FollowUps() ([]FollowUpQuestion, map[uint]string, error) {
+	followUps := []FollowUpQuestion{}
+	if err := GetDB().Where("poll_id = ?", poll.ID).Order("id").Find(&followUps).Error; err != nil {
+		return nil, nil, err
+	}
+
+	answers, err := poll.GetAnswers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[uint]string)
+	for _, answer := range answers {
+		values[answer.ID] = answer.Value
+	}
+	return followUps, values, nil
+}
+
+func findFollowUpForAnswer(pollID uint, answer string) *FollowUpQuestion {
+	followUp := &FollowUpQuestion{}
+	GetDB().
+		Joins("JOIN possible_answers ON possible_answers.id = follow_up_questions.possible_answer_id").
+		Where("follow_up_questions.poll_id = ? AND possible_answers.value = ?", pollID, answer).
+		First(followUp)
+	return followUp
+}
+
+// copyFollowUps gives a cloned poll the same follow ups as the original. The possible answers are new rows
+// on the clone so they are matched up by value
+func copyFollowUps(from, to *Poll) error {
+	followUps, values, err := from.FollowUps()
+	if err != nil {
+		return err
+	}
+
+	for _, followUp := range followUps {
+		if _, err := to.AddFollowUp(values[followUp.PossibleAnswerID], followUp.Question); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingFollowUps are the follow up questions the user has been asked but not answered yet. Follow ups for
+// answers the user has since changed no longer count, nor do follow ups on polls which are no longer taking
+// answers because they were closed, cancelled or archived
+func pendingFollowUps(slackID string) *gorm.DB {
+	return GetDB().
+		Joins("JOIN poll_responses ON poll_responses.id = follow_up_responses.poll_response_id AND poll_responses.deleted_at IS NULL").
+		Joins("JOIN polls ON polls.id = poll_responses.poll_id AND polls.deleted_at IS NULL").
+		Where("poll_responses.slack_id = ? AND follow_up_responses.answered_at IS NULL", slackID).
+		Where("polls.stage = ?", StageActive).
+		Order("follow_up_responses.created_at, follow_up_responses.id")
+}
+
+// FindPendingFollowUp is the oldest follow up waiting on an answer from the user
+func FindPendingFollowUp(slackID string) *FollowUpResponse {
+	pending := &FollowUpResponse{}
+	pendingFollowUps(slackID).First(pending)
+	return pending
+}
+
+// FindPendingFollowUpForPoll is the follow up waiting on an answer from the user for a particular poll
+func FindPendingFollowUpForPoll(slackID string, pollID uint) *FollowUpResponse {
+	pending := &FollowUpResponse{}
+	pendingFollowUps(slackID).Where("poll_responses.poll_id = ?", pollID).First(pending)
+	return pending
+}
+
+func (pending *FollowUpResponse) Answer(value string, now time.Time) error {
+	pending.Value = value
+	pending.AnsweredAt = &now
+	return GetDB().Save(pending).Error
+}
+
+// Poll is the poll the follow up was asked for
+func (pending *FollowUpResponse) Poll() (*Poll, error) {
+	response := PollResponse{}
+	GetDB().Where("id = ?", pending.PollResponseID).First(&response)
+
+	poll := &Poll{}
+	GetDB().Where("id = ?", response.PollID).First(poll)
+	if poll.ID == 0 {
+		return nil, fmt.Errorf("Unable to find the poll for follow up response %d", pending.ID)
+	}
+	return poll, nil
+}
+
+// followUpAnswers are the answers given to a follow up question by people who still have the answer which
+// caused it to be asked
+func followUpAnswers(followUp *FollowUpQuestion) []string {
+	responses := []FollowUpResponse{}
+	GetDB().
+		Joins("JOIN poll_responses ON poll_responses.id = follow_up_responses.poll_response_id AND poll_responses.deleted_at IS NULL").
+		Where("follow_up_responses.follow_up_question_id = ? AND follow_up_responses.answered_at IS NOT NULL", followUp.ID).
+		Order("follow_up_responses.answered_at").
+		Find(&responses)
+
+	answers := []string{}
+	for _, response := range responses {
+		answers = append(answers, response.Value)
+	}
+	return answers
+}
+
+// followUpFields nests the answers to each follow up question under the answer it was asked for
+func followUpFields(poll *Poll) []AttachmentField {
+	followUps, values, err := poll.FollowUps()
+	if err != nil {
+		return nil
+	}
+
+	fields := []AttachmentField{}
+	for i := range followUps {
+		lines := []string{}
+		for j, answer := range followUpAnswers(&followUps[i]) {
+			lines = append(lines, fmt.Sprintf(" %d.%s", j+1, answer))
+		}
+
+		if len(lines) == 0 {
+			lines = append(lines, "No answers yet")
+		}
+
+		fields = append(fields, AttachmentField{
+			Title: fmt.Sprintf("Answered %s - %s", values[followUps[i].PossibleAnswerID], followUps[i].Question),
+			Value: strings.Join(lines, "\n"),
+			Short: false,
+		})
+	}
+	return fields
+}
+
+func followUpPreviewField(poll *Poll) *AttachmentField {
+	followUps, values, err := poll.FollowUps()
+	if err != nil || len(followUps) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	for _, followUp := range followUps {
+		lines = append(lines, fmt.Sprintf("%s → %s", values[followUp.PossibleAnswerID], followUp.Question))
+	}
+
+	return &AttachmentField{
+		Title: "Follow Ups:",
+		Value: strings.Join(lines, "\n"),
+		Short: false,
+	}
+}
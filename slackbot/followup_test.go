@@ -0,0 +1,105 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFollowUpIsAskedForAnswer(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	poll := &Poll{
+		Kind:            ResponsePoll,
+		UUID:            "oncall",
+		Creator:         "boss",
+		Stage:           "active",
+		Question:        "Are you happy with on-call?",
+		PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}},
+		Recipients:      []Recipient{{SlackID: "U1"}, {SlackID: "U2"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := poll.AddFollowUp("maybe", "Why maybe?"); err != ErrUnknownAnswer {
+		t.Fatal("Expected follow up for an unknown answer to be rejected got: ", err)
+	}
+
+	if _, err := poll.AddFollowUp("no", "What would help?"); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := func(user, text string) string {
+		memory.Reset()
+		robot.Dispatch(&Message{User: user, Channel: "D" + user, Text: text})
+		return strings.Join(memory.SentTexts(), "|")
+	}
+
+	if output := answer("U2", "answer poll oncall yes"); strings.Contains(output, "What would help?") {
+		t.Error("Expected no follow up for yes got: ", output)
+	}
+
+	if output := answer("U1", "answer poll oncall no"); !strings.Contains(output, "What would help?") {
+		t.Error("Expected the follow up to be asked got: ", output)
+	}
+
+	if output := answer("U1", "answer poll oncall no"); strings.Contains(output, "What would help?") {
+		t.Error("Expected the follow up not to be asked again for the same answer got: ", output)
+	}
+
+	if output := answer("U1", "More people on the rotation"); output != "Thanks for the extra detail!" {
+		t.Error("Expected the reply to answer the follow up got: ", output)
+	}
+
+	if pending := FindPendingFollowUp("U1"); pending.ID != 0 {
+		t.Error("Expected no more follow ups waiting on U1")
+	}
+
+	summary := poll.SlackPollSummary()
+	nested := summary.Fields[1]
+	if nested.Title != "Answered no - What would help?" || nested.Value != " 1.More people on the rotation" {
+		t.Error("Expected the follow up answers to be nested under no got: ", nested.Title, nested.Value)
+	}
+
+	// Changing the answer means the follow up answer no longer applies
+	answer("U1", "answer poll oncall yes")
+	summary = poll.SlackPollSummary()
+	if summary.Fields[1].Value != "No answers yet" {
+		t.Error("Expected the follow up answer to be dropped after changing answer got: ", summary.Fields[1].Value)
+	}
+}
+
+func TestFollowUpsStopWaitingOnceThePollCloses(t *testing.T) {
+	robot := CleanSetup()
+
+	poll := &Poll{
+		Kind:            ResponsePoll,
+		UUID:            "retro",
+		Creator:         "boss",
+		Stage:           "active",
+		Question:        "Was the retro useful?",
+		PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}},
+		Recipients:      []Recipient{{SlackID: "U1"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := poll.AddFollowUp("no", "What would make it better?"); err != nil {
+		t.Fatal(err)
+	}
+
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "answer poll retro no"})
+	if pending := FindPendingFollowUp("U1"); pending.ID == 0 {
+		t.Fatal("Expected the follow up to be waiting on U1")
+	}
+
+	if err := poll.TransitionTo(StageClosed, systemActor); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := FindPendingFollowUp("U1"); pending.ID != 0 {
+		t.Error("Expected the follow up to stop waiting once the poll closed")
+	}
+}
@@ -0,0 +1,226 @@
+package slackbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var (
+	ErrInvalidSignature = errors.New("CarlosTheCurious: Slack request signature did not match")
+	ErrStaleRequest     = errors.New("CarlosTheCurious: Slack request timestamp is too old")
+
+	// Requests older than this are rejected to stop someone replaying a request they captured
+	maxRequestAge = 5 * time.Minute
+)
+
+// verifySlackSignature checks the request was signed by Slack with our signing secret
+// https://api.slack.com/docs/verifying-requests-from-slack
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	if secret == "" {
+		return errors.New("CarlosTheCurious: No signing secret configured to verify Slack requests")
+	}
+
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return ErrStaleRequest
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// readVerifiedBody reads the request body and makes sure it really came from Slack
+func (robot *Robot) readVerifiedBody(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return body, verifySlackSignature(robot.SigningSecret, r.Header, body, time.Now())
+}
+
+func answeredAttachment(poll *Poll, answer string) Attachment {
+	attachment := poll.SlackRecipientAttachment()
+	attachment.Actions = nil
+	attachment.Footer = fmt.Sprintf("You answered: %s", answer)
+	return attachment
+}
+
+// handleAction records the answer behind a button click and works out how the original message should change.
+// Anything that can wait, such as follow ups and refreshing a channel poll's tallies, is handed back to be done
+// after Slack has had its answer
+func (robot *Robot) handleAction(payload *ActionPayload) (*ActionResponse, func()) {
+	if len(payload.Actions) == 0 {
+		return nil, nil
+	}
+
+	poll, err := FindFirstPublishedPollByUUID(payload.CallbackID)
+	if err != nil {
+		return &ActionResponse{
+			Text:         fmt.Sprintf("Sorry about this but didn't not find a poll %s", payload.CallbackID),
+			ResponseType: "ephemeral",
+		}, nil
+	}
+
+	answer := payload.Actions[0].Value
+	if poll.Kind == MultipleChoicePoll {
+		return robot.handleSelection(payload, poll, answer)
+	}
+
+	previous, err := poll.AddResponse(payload.User.ID, answer)
+	if err != nil {
+		return actionErrorResponse(poll, err), nil
+	}
+
+	return answeredResponse(poll, answeredMessage(previous, answer), answeredAttachment(poll, answer)), robot.afterAction(payload, poll, previous)
+}
+
+// handleSelection picks or unpicks the clicked answer of a multiple choice poll. The buttons stay on the message
+// so the recipient can keep picking answers
+func (robot *Robot) handleSelection(payload *ActionPayload, poll *Poll, clicked string) (*ActionResponse, func()) {
+	userID := payload.User.ID
+	current := poll.CurrentResponse(userID)
+	selections := toggleSelection(splitSelections(current.Value), clicked)
+	if len(selections) == 0 {
+		return &ActionResponse{
+			Text:         "You need to pick at least one answer. Pick another answer before removing this one",
+			ResponseType: "ephemeral",
+		}, nil
+	}
+
+	previous, err := poll.AddResponse(userID, strings.Join(selections, ","))
+	if err != nil {
+		return actionErrorResponse(poll, err), nil
+	}
+
+	answer := poll.NormaliseResponse(strings.Join(selections, ","))
+	attachment := poll.SlackRecipientAttachment()
+	attachment.Footer = fmt.Sprintf("You picked: %s. Click an answer again to remove it", answer)
+	return answeredResponse(poll, answeredMessage(previous, answer), attachment), robot.afterAction(payload, poll, previous)
+}
+
+// answeredResponse swaps the clicked message for one showing the answer. A channel poll is the same message
+// for everyone so instead the clicker is told privately and the tallies on the message are updated afterwards
+func answeredResponse(poll *Poll, text string, attachment Attachment) *ActionResponse {
+	if !poll.postsInChannel() {
+		return &ActionResponse{
+			Text:            text,
+			Attachments:     []Attachment{attachment},
+			ReplaceOriginal: true,
+		}
+	}
+
+	return &ActionResponse{
+		Text:         attachment.Footer,
+		ResponseType: "ephemeral",
+	}
+}
+
+// afterAction asks any follow up or sends the next survey question once a button answers a poll and updates
+// the tallies of a channel poll. Follow ups go out as new messages so the answered question stays in the
+// conversation
+func (robot *Robot) afterAction(payload *ActionPayload, poll *Poll, previous *PollResponse) func() {
+	return func() {
+		// Follow ups for a channel poll are asked privately rather than in the channel
+		channel := payload.Channel.ID
+		if channel == "" || poll.postsInChannel() {
+			channel = payload.User.ID
+		}
+
+		if err := followAnswer(robot, channel, poll, payload.User.ID, previous); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"poll_uuid": poll.UUID,
+				"user":      payload.User.ID,
+			}).Error("Error following up on answer: ", err)
+		}
+
+		if !poll.postsInChannel() {
+			return
+		}
+
+		if err := refreshPostedPoll(robot, poll); err != nil {
+			logrus.WithField("poll_uuid", poll.UUID).Error("Error updating the posted poll: ", err)
+		}
+	}
+}
+
+func actionErrorResponse(poll *Poll, err error) *ActionResponse {
+	if err == ErrPollClosed && !poll.postsInChannel() {
+		attachment := poll.SlackRecipientAttachment()
+		attachment.Actions = nil
+		return &ActionResponse{
+			Text:            "Sorry, that poll has closed and is no longer accepting answers",
+			Attachments:     []Attachment{attachment},
+			ReplaceOriginal: true,
+		}
+	}
+
+	return &ActionResponse{
+		Text:         answerErrorMessage(err),
+		ResponseType: "ephemeral",
+	}
+}
+
+// InteractiveHandler receives the payload Slack posts when someone clicks one of the answer buttons
+func (robot *Robot) InteractiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := robot.readVerifiedBody(r)
+	if err != nil {
+		logrus.Warn("Rejected interactive request: ", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	payload := &ActionPayload{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), payload); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Slack gives up on a request after 3 seconds so it is answered before any follow ups are sent
+	response, after := robot.handleAction(payload)
+	if response == nil {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+
+	if after != nil {
+		go after()
+	}
+}
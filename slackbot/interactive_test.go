@@ -0,0 +1,237 @@
+package slackbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signRequest(req *http.Request, secret, body string, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Now()
+	body := "payload=hello"
+
+	var testCases = []struct {
+		SigningSecret string
+		VerifySecret  string
+		SignedAt      time.Time
+		Body          string
+		ExpectedError bool
+	}{
+		{SigningSecret: "shhh", VerifySecret: "shhh", SignedAt: now, Body: body, ExpectedError: false},
+		{SigningSecret: "shhh", VerifySecret: "nope", SignedAt: now, Body: body, ExpectedError: true},
+		{SigningSecret: "shhh", VerifySecret: "shhh", SignedAt: now, Body: "payload=tampered", ExpectedError: true},
+		{SigningSecret: "shhh", VerifySecret: "shhh", SignedAt: now.Add(-10 * time.Minute), Body: body, ExpectedError: true},
+		{SigningSecret: "", VerifySecret: "", SignedAt: now, Body: body, ExpectedError: true},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", "/slack/actions", strings.NewReader(body))
+		signRequest(req, testCase.SigningSecret, body, testCase.SignedAt)
+
+		err := verifySlackSignature(testCase.VerifySecret, req.Header, []byte(testCase.Body), now)
+		if testCase.ExpectedError && err == nil {
+			t.Error("Expected signature verification to fail for ", testCase)
+		} else if !testCase.ExpectedError && err != nil {
+			t.Error("Was not expecting signature verification to fail: ", err)
+		}
+	}
+}
+
+func TestSlackRecipientAttachmentHasAnswerButtons(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := Poll{Kind: ResponsePoll, UUID: "buttons", PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}}}
+	GetDB().Save(&poll)
+
+	attachment := poll.SlackRecipientAttachment()
+	if attachment.CallbackID != "buttons" {
+		t.Error("Expected callback id to be the poll uuid got: ", attachment.CallbackID)
+	}
+
+	if len(attachment.Actions) != 2 {
+		t.Fatal("Expected a button for each possible answer got: ", len(attachment.Actions))
+	}
+
+	for k, expected := range []string{"yes", "no"} {
+		if attachment.Actions[k].Value != expected || attachment.Actions[k].Type != "button" {
+			t.Error("Expected button with value: ", expected, " got: ", attachment.Actions[k])
+		}
+	}
+}
+
+func TestInteractiveHandlerRecordsResponse(t *testing.T) {
+	robot := CleanSetup()
+	robot.SigningSecret = "shhh"
+
+	poll := Poll{Kind: ResponsePoll, UUID: "clicky", Stage: "active", PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}}, Recipients: []Recipient{{SlackID: "U123"}}}
+	GetDB().Save(&poll)
+
+	payload := `{"type":"interactive_message","callback_id":"clicky","actions":[{"name":"answer","type":"button","value":"yes"}],"user":{"id":"U123","name":"dana"}}`
+	body := url.Values{"payload": {payload}}.Encode()
+
+	var testCases = []struct {
+		Sign           bool
+		ExpectedStatus int
+	}{
+		{Sign: false, ExpectedStatus: http.StatusUnauthorized},
+		{Sign: true, ExpectedStatus: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", "/slack/actions", strings.NewReader(body))
+		if testCase.Sign {
+			signRequest(req, robot.SigningSecret, body, time.Now())
+		}
+
+		recorder := httptest.NewRecorder()
+		robot.InteractiveHandler(recorder, req)
+		if recorder.Code != testCase.ExpectedStatus {
+			t.Fatal("Expected status: ", testCase.ExpectedStatus, " got: ", recorder.Code)
+		}
+	}
+
+	responses, _ := poll.GetResponses()
+	if len(responses) != 1 || responses[0].Value != "yes" || responses[0].SlackID != "U123" {
+		t.Fatal("Expected a single yes response from U123 got: ", responses)
+	}
+}
+
+func TestHandleActionUpdatesOriginalMessage(t *testing.T) {
+	robot := CleanSetup()
+
+	poll := Poll{Kind: ResponsePoll, UUID: "clicky", Stage: "active", PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}}, Recipients: []Recipient{{SlackID: "U123"}}}
+	GetDB().Save(&poll)
+
+	response, _ := robot.handleAction(&ActionPayload{
+		CallbackID: "clicky",
+		Actions:    []Action{{Name: "answer", Value: "no"}},
+		User:       PayloadEntity{ID: "U123"},
+	})
+
+	encoded, _ := json.Marshal(response)
+	if !response.ReplaceOriginal {
+		t.Error("Expected the original message to be replaced got: ", string(encoded))
+	}
+
+	if len(response.Attachments) != 1 || len(response.Attachments[0].Actions) != 0 {
+		t.Fatal("Expected the buttons to be removed from the message got: ", string(encoded))
+	}
+
+	if response.Attachments[0].Footer != "You answered: no" {
+		t.Error("Expected the message to show the answer got: ", response.Attachments[0].Footer)
+	}
+}
+
+func TestHandleActionTogglesMultipleChoiceSelections(t *testing.T) {
+	robot := CleanSetup()
+
+	poll := Poll{Kind: MultipleChoicePoll, UUID: "clicky", Stage: "active", PossibleAnswers: []PossibleAnswer{{Value: "tea"}, {Value: "coffee"}, {Value: "juice"}}, Recipients: []Recipient{{SlackID: "U123"}}}
+	GetDB().Save(&poll)
+
+	var testCases = []struct {
+		Clicked        string
+		ExpectedAnswer string
+		ExpectedFooter string
+	}{
+		{Clicked: "juice", ExpectedAnswer: "juice", ExpectedFooter: "You picked: juice. Click an answer again to remove it"},
+		{Clicked: "tea", ExpectedAnswer: "tea, juice", ExpectedFooter: "You picked: tea, juice. Click an answer again to remove it"},
+		{Clicked: "juice", ExpectedAnswer: "tea", ExpectedFooter: "You picked: tea. Click an answer again to remove it"},
+	}
+
+	for _, testCase := range testCases {
+		response, _ := robot.handleAction(&ActionPayload{
+			CallbackID: "clicky",
+			Actions:    []Action{{Name: "answer", Value: testCase.Clicked}},
+			User:       PayloadEntity{ID: "U123"},
+		})
+
+		encoded, _ := json.Marshal(response)
+		if len(response.Attachments) != 1 || len(response.Attachments[0].Actions) != 3 {
+			t.Fatal("Expected the buttons to stay on the message got: ", string(encoded))
+		}
+
+		if response.Attachments[0].Footer != testCase.ExpectedFooter {
+			t.Error("Expected footer: ", testCase.ExpectedFooter, " got: ", response.Attachments[0].Footer)
+		}
+
+		if current := poll.CurrentResponse("U123"); current.Value != testCase.ExpectedAnswer {
+			t.Error("Expected current answer: ", testCase.ExpectedAnswer, " got: ", current.Value)
+		}
+	}
+
+	// Removing the last pick is turned away so the recipient always has an answer
+	response, _ := robot.handleAction(&ActionPayload{
+		CallbackID: "clicky",
+		Actions:    []Action{{Name: "answer", Value: "tea"}},
+		User:       PayloadEntity{ID: "U123"},
+	})
+
+	if response.ReplaceOriginal || response.ResponseType != "ephemeral" {
+		t.Error("Expected removing the last answer to be turned away")
+	}
+}
+
+func TestHandleActionUpdatesChannelPollTallies(t *testing.T) {
+	robot := CleanSetup()
+
+	poll := Poll{
+		Kind:            ResponsePoll,
+		UUID:            "team-lunch",
+		Stage:           "active",
+		Delivery:        ChannelDelivery,
+		PostChannel:     "CTEAM",
+		PostTS:          "1500000000.000100",
+		PossibleAnswers: []PossibleAnswer{{Value: "pizza"}, {Value: "tacos"}},
+		Recipients:      []Recipient{{SlackID: "U123"}, {SlackID: "U456"}},
+	}
+	GetDB().Save(&poll)
+
+	response, after := robot.handleAction(&ActionPayload{
+		CallbackID: "team-lunch",
+		Actions:    []Action{{Name: "answer", Value: "tacos"}},
+		Channel:    PayloadEntity{ID: "CTEAM"},
+		User:       PayloadEntity{ID: "U123"},
+	})
+
+	encoded, _ := json.Marshal(response)
+	if response.ReplaceOriginal || response.ResponseType != "ephemeral" || response.Text != "You answered: tacos" {
+		t.Fatal("Expected the answer to be confirmed privately and the shared message left alone got: ", string(encoded))
+	}
+
+	// The tallies are only refreshed once Slack has been answered
+	if updates := robot.Transport.(*MemoryTransport).Updates(); len(updates) != 0 {
+		t.Fatal("Expected the posted poll to be left until after answering got updates: ", len(updates))
+	}
+	after()
+
+	updates := robot.Transport.(*MemoryTransport).Updates()
+	if len(updates) != 1 {
+		t.Fatal("Expected the posted poll to be updated got updates: ", len(updates))
+	}
+
+	update := updates[0]
+	if update.TS != "1500000000.000100" || update.Channel != "CTEAM" {
+		t.Error("Expected the posted message to be updated got: ", update.Channel, update.TS)
+	}
+
+	attachments := update.Attachments
+	if len(attachments) != 1 || len(attachments[0].Actions) != 2 || !strings.Contains(attachmentsText(attachments), "1 out of 2") {
+		t.Error("Expected the tallies to be updated with the buttons kept got: ", attachmentsText(attachments))
+	}
+}
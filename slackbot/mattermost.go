@@ -0,0 +1,597 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"golang.org/x/net/websocket"
+)
+
+// Mattermost runs Carlos on a Mattermost server instead of Slack. Messages arrive over the Mattermost websocket
+// and everything is sent with its REST API
+const Mattermost = "mattermost"
+
+const mattermostPageSize = 200
+
+// Groups reuses the channels Channels just fetched when they are this fresh, refreshing both is one walk over
+// every channel's members rather than two
+const mattermostListingReuse = time.Minute
+
+var (
+	mattermostMentionRegex = regexp.MustCompile(`(^|[^\w@.])@([a-zA-Z0-9._-]+)`)
+	mattermostChannelRegex = regexp.MustCompile(`(^|[^\w~])~([a-z0-9_-]+)`)
+)
+
+type mattermostUser struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	IsBot     bool   `json:"is_bot"`
+	DeleteAt  int64  `json:"delete_at"`
+}
+
+type mattermostChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"` // O is public, P is private and D is a direct message
+}
+
+type mattermostMember struct {
+	UserID string `json:"user_id"`
+}
+
+type mattermostTeam struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type mattermostPost struct {
+	ID        string                 `json:"id,omitempty"`
+	ChannelID string                 `json:"channel_id"`
+	UserID    string                 `json:"user_id,omitempty"`
+	RootID    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	Type      string                 `json:"type,omitempty"` // Set for system messages like someone joining
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+// mattermostEvent is anything sent over the Mattermost websocket. The post in a posted event is itself JSON
+type mattermostEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Post        string `json:"post"`
+		ChannelType string `json:"channel_type"`
+	} `json:"data"`
+}
+
+type mattermostAuthentication struct {
+	Seq    int               `json:"seq"`
+	Action string            `json:"action"`
+	Data   map[string]string `json:"data"`
+}
+
+type mattermostError struct {
+	Message string `json:"message"`
+}
+
+// MattermostTransport is the robot's way into a Mattermost server. Mattermost writes mentions as @username and
+// ~channel so messages are translated into the Slack markup the commands understand on the way in and back again
+// on the way out
+type MattermostTransport struct {
+	URL    string // Where the server is, e.g. https://chat.example.com
+	Token  string // Personal access token of the bot account
+	Client WebClienter
+
+	conn *rtmConnection
+	self Identity
+
+	mu             sync.Mutex
+	usernames      map[string]string // user id -> username
+	userIDs        map[string]string // username -> user id
+	channelIDs     map[string]string // channel name -> channel id
+	directChannels map[string]string // user id -> direct message channel id
+	listing        *mattermostListing
+}
+
+// mattermostListing is the result of one teamChannels call kept for Groups to pick up
+type mattermostListing struct {
+	channels  []mattermostChannel
+	members   map[string][]string
+	fetchedAt time.Time
+}
+
+func NewMattermostTransport(url, token string) *MattermostTransport {
+	return &MattermostTransport{
+		URL:    strings.TrimRight(url, "/"),
+		Token:  token,
+		Client: &http.Client{},
+		conn:   &rtmConnection{},
+	}
+}
+
+// api makes a request to the Mattermost REST API and decodes the answer into result
+func (mattermost *MattermostTransport) api(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, mattermost.URL+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+mattermost.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := mattermost.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiError := &mattermostError{}
+		json.Unmarshal(data, apiError)
+		if resp.StatusCode == http.StatusUnauthorized {
+			return &ErrNotAuthorized{Reason: apiError.Message}
+		}
+		return fmt.Errorf("Mattermost %s %s failed with %d: %s", method, path, resp.StatusCode, apiError.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (mattermost *MattermostTransport) Connect() (*Identity, error) {
+	me := &mattermostUser{}
+	if err := mattermost.api("GET", "/users/me", nil, me); err != nil {
+		return nil, err
+	}
+	mattermost.self = Identity{ID: me.ID, Name: me.Username}
+	mattermost.rememberUser(*me)
+
+	if err := mattermost.connectWebsocket(); err != nil {
+		return nil, err
+	}
+	return &mattermost.self, nil
+}
+
+// connectWebsocket opens the websocket events arrive on and authenticates with the same token as the REST API
+func (mattermost *MattermostTransport) connectWebsocket() error {
+	url := strings.Replace(mattermost.URL, "http", "ws", 1) + "/api/v4/websocket"
+	websock, err := dialWebsocket(url, mattermost.URL)
+	if err != nil {
+		return err
+	}
+
+	auth := &mattermostAuthentication{
+		Seq:    1,
+		Action: "authentication_challenge",
+		Data:   map[string]string{"token": mattermost.Token},
+	}
+	if err := websocket.JSON.Send(websock, auth); err != nil {
+		websock.Close()
+		return err
+	}
+
+	if mattermost.conn == nil {
+		mattermost.conn = &rtmConnection{}
+	}
+	mattermost.conn.set(websock)
+
+	logrus.Info("Connected to Mattermost!")
+	return nil
+}
+
+// reconnect keeps trying to open a new websocket until one works
+func (mattermost *MattermostTransport) reconnect() {
+	mattermost.conn.close()
+
+	backoff := &Backoff{Min: minReconnectDelay, Max: maxReconnectDelay}
+	for {
+		err := mattermost.connectWebsocket()
+		if err == nil {
+			return
+		}
+
+		delay := backoff.Next()
+		logrus.WithField("retry_in", delay).Warn("Unable to reconnect to Mattermost: ", err)
+		time.Sleep(delay)
+	}
+}
+
+// Listen passes posts from the Mattermost websocket on to the workers
+func (mattermost *MattermostTransport) Listen(robot *Robot) {
+	go func() {
+		for {
+			event := &mattermostEvent{}
+			err := websocket.JSON.Receive(mattermost.conn.get(), event)
+			if err != nil {
+				logrus.Error("Error receiving from Mattermost: ", err.Error())
+				if isDisconnect(err) {
+					mattermost.reconnect()
+				}
+				continue
+			}
+
+			if msg, ok := mattermost.eventMessage(event); ok {
+				robot.ListenChan <- msg
+			}
+		}
+	}()
+}
+
+// eventMessage turns a posted event into the message the Slack RTM would have sent us. Carlos's own posts and
+// system messages are left out
+func (mattermost *MattermostTransport) eventMessage(event *mattermostEvent) (Message, bool) {
+	if event.Event != "posted" {
+		return Message{}, false
+	}
+
+	post := &mattermostPost{}
+	if err := json.Unmarshal([]byte(event.Data.Post), post); err != nil {
+		logrus.Warn("Unable to read post from Mattermost: ", err)
+		return Message{}, false
+	}
+
+	if post.Type != "" || post.UserID == "" || post.UserID == mattermost.self.ID {
+		return Message{}, false
+	}
+
+	msg := Message{
+		Type:      "message",
+		Channel:   post.ChannelID,
+		User:      post.UserID,
+		Text:      mattermost.fromMattermost(post.Message),
+		Timestamp: post.ID,
+		ThreadTS:  post.RootID,
+	}
+	if event.Data.ChannelType == "D" {
+		msg.ChannelType = "im"
+	}
+	return msg, true
+}
+
+// fromMattermost rewrites @username, @channel and ~channel as the Slack markup the commands look for. Names we
+// don't know are left alone and end up reported back as unknown
+func (mattermost *MattermostTransport) fromMattermost(text string) string {
+	mattermost.mu.Lock()
+	defer mattermost.mu.Unlock()
+
+	text = mattermostMentionRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := mattermostMentionRegex.FindStringSubmatch(match)
+		name := strings.TrimRight(parts[2], ".-_")
+		rest := parts[2][len(name):]
+
+		switch name {
+		case "channel", "here":
+			return parts[1] + "<!" + name + ">" + rest
+		case "all":
+			return parts[1] + "<!everyone>" + rest
+		}
+
+		if id, ok := mattermost.userIDs[strings.ToLower(name)]; ok {
+			return parts[1] + "<@" + id + ">" + rest
+		}
+		return match
+	})
+
+	return mattermostChannelRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := mattermostChannelRegex.FindStringSubmatch(match)
+		if id, ok := mattermost.channelIDs[parts[2]]; ok {
+			return parts[1] + "<#" + id + "|" + parts[2] + ">"
+		}
+		return match
+	})
+}
+
+// toMattermost undoes fromMattermost for everything Carlos says
+func (mattermost *MattermostTransport) toMattermost(text string) string {
+	mattermost.mu.Lock()
+	defer mattermost.mu.Unlock()
+
+	text = slackIDRegex.ReplaceAllStringFunc(text, func(match string) string {
+		id := slackIDRegex.FindStringSubmatch(match)[1]
+		if name, ok := mattermost.usernames[id]; ok {
+			return "@" + name
+		}
+		return match
+	})
+
+	text = slackChannelIDRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := slackChannelIDRegex.FindStringSubmatch(match)
+		if parts[2] != "" {
+			return "~" + parts[2]
+		}
+
+		for name, id := range mattermost.channelIDs {
+			if id == parts[1] {
+				return "~" + name
+			}
+		}
+		return match
+	})
+
+	return slackWholeChannelRegex.ReplaceAllStringFunc(text, func(match string) string {
+		switch slackWholeChannelRegex.FindStringSubmatch(match)[1] {
+		case "here":
+			return "@here"
+		case "everyone":
+			return "@all"
+		}
+		return "@channel"
+	})
+}
+
+// toMattermostAttachments keeps everything Mattermost can show. Buttons are left out, people answer in the
+// conversation instead
+func (mattermost *MattermostTransport) toMattermostAttachments(attachments []Attachment) []Attachment {
+	translated := []Attachment{}
+	for _, attachment := range attachments {
+		attachment.Fallback = mattermost.toMattermost(attachment.Fallback)
+		attachment.Pretext = mattermost.toMattermost(attachment.Pretext)
+		attachment.Text = mattermost.toMattermost(attachment.Text)
+		attachment.Footer = mattermost.toMattermost(attachment.Footer)
+		attachment.Actions = nil
+
+		fields := []AttachmentField{}
+		for _, field := range attachment.Fields {
+			field.Value = mattermost.toMattermost(field.Value)
+			fields = append(fields, field)
+		}
+		attachment.Fields = fields
+
+		translated = append(translated, attachment)
+	}
+	return translated
+}
+
+// channelFor finds where to post. Polls are sent to user ids, which Mattermost needs turned into the direct
+// message channel between Carlos and that person
+func (mattermost *MattermostTransport) channelFor(id string) (string, error) {
+	mattermost.mu.Lock()
+	_, isUser := mattermost.usernames[id]
+	direct, known := mattermost.directChannels[id]
+	mattermost.mu.Unlock()
+
+	if !isUser || id == mattermost.self.ID {
+		return id, nil
+	}
+
+	if known {
+		return direct, nil
+	}
+
+	channel := &mattermostChannel{}
+	if err := mattermost.api("POST", "/channels/direct", []string{mattermost.self.ID, id}, channel); err != nil {
+		return "", err
+	}
+
+	mattermost.mu.Lock()
+	mattermost.directChannels[id] = channel.ID
+	mattermost.mu.Unlock()
+	return channel.ID, nil
+}
+
+func (mattermost *MattermostTransport) createPost(channel, threadTS, text string, attachments []Attachment) (*mattermostPost, error) {
+	channelID, err := mattermost.channelFor(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	post := &mattermostPost{ChannelID: channelID, RootID: threadTS, Message: mattermost.toMattermost(text)}
+	if len(attachments) > 0 {
+		post.Props = map[string]interface{}{"attachments": mattermost.toMattermostAttachments(attachments)}
+	}
+
+	created := &mattermostPost{}
+	if err := mattermost.api("POST", "/posts", post, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (mattermost *MattermostTransport) SendMessage(channel, text string) error {
+	_, err := mattermost.createPost(channel, "", text, nil)
+	return err
+}
+
+func (mattermost *MattermostTransport) ReplyInThread(channel, threadTS, text string) error {
+	_, err := mattermost.createPost(channel, threadTS, text, nil)
+	return err
+}
+
+// PostAttachments hands back the post id as the timestamp, which is what threads and updates refer to
+func (mattermost *MattermostTransport) PostAttachments(channel, text string, attachments []Attachment) (*PostResponse, error) {
+	post, err := mattermost.createPost(channel, "", text, attachments)
+	if err != nil {
+		return nil, err
+	}
+	return &PostResponse{Ok: true, Channel: post.ChannelID, TS: post.ID}, nil
+}
+
+func (mattermost *MattermostTransport) UpdateMessage(channel, ts, text string, attachments []Attachment) error {
+	patch := map[string]interface{}{
+		"message": mattermost.toMattermost(text),
+		"props":   map[string]interface{}{"attachments": mattermost.toMattermostAttachments(attachments)},
+	}
+	return mattermost.api("PUT", "/posts/"+ts+"/patch", patch, nil)
+}
+
+func (mattermost *MattermostTransport) rememberUser(user mattermostUser) {
+	mattermost.mu.Lock()
+	defer mattermost.mu.Unlock()
+
+	if mattermost.usernames == nil {
+		mattermost.usernames = make(map[string]string)
+		mattermost.userIDs = make(map[string]string)
+		mattermost.directChannels = make(map[string]string)
+	}
+	mattermost.usernames[user.ID] = user.Username
+	mattermost.userIDs[strings.ToLower(user.Username)] = user.ID
+}
+
+func (mattermost *MattermostTransport) Users() ([]User, error) {
+	users := []User{}
+	for page := 0; ; page++ {
+		batch := []mattermostUser{}
+		path := fmt.Sprintf("/users?page=%d&per_page=%d", page, mattermostPageSize)
+		if err := mattermost.api("GET", path, nil, &batch); err != nil {
+			return nil, err
+		}
+
+		for _, user := range batch {
+			mattermost.rememberUser(user)
+			users = append(users, User{
+				Name:    user.Username,
+				SlackID: user.ID,
+				SlackProfile: SlackProfile{
+					FirstName: user.FirstName,
+					LastName:  user.LastName,
+					RealName:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+					Email:     user.Email,
+				},
+				Deleted: user.DeleteAt > 0,
+				IsBot:   user.IsBot,
+			})
+		}
+
+		if len(batch) < mattermostPageSize {
+			return users, nil
+		}
+	}
+}
+
+// teamChannels is every channel Carlos is in across all of its teams along with who is in them
+func (mattermost *MattermostTransport) teamChannels() ([]mattermostChannel, map[string][]string, error) {
+	teams := []mattermostTeam{}
+	if err := mattermost.api("GET", "/users/me/teams", nil, &teams); err != nil {
+		return nil, nil, err
+	}
+
+	channels := []mattermostChannel{}
+	members := make(map[string][]string)
+	for _, team := range teams {
+		teamChannels := []mattermostChannel{}
+		if err := mattermost.api("GET", "/users/me/teams/"+team.ID+"/channels", nil, &teamChannels); err != nil {
+			return nil, nil, err
+		}
+
+		for _, channel := range teamChannels {
+			if channel.Type != "O" && channel.Type != "P" {
+				continue
+			}
+
+			ids, err := mattermost.channelMembers(channel.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			members[channel.ID] = ids
+			channels = append(channels, channel)
+		}
+	}
+
+	mattermost.mu.Lock()
+	mattermost.channelIDs = make(map[string]string)
+	for _, channel := range channels {
+		mattermost.channelIDs[channel.Name] = channel.ID
+	}
+	mattermost.mu.Unlock()
+	return channels, members, nil
+}
+
+func (mattermost *MattermostTransport) channelMembers(id string) ([]string, error) {
+	ids := []string{}
+	for page := 0; ; page++ {
+		batch := []mattermostMember{}
+		path := fmt.Sprintf("/channels/%s/members?page=%d&per_page=%d", id, page, mattermostPageSize)
+		if err := mattermost.api("GET", path, nil, &batch); err != nil {
+			return nil, err
+		}
+
+		for _, member := range batch {
+			ids = append(ids, member.UserID)
+		}
+
+		if len(batch) < mattermostPageSize {
+			return ids, nil
+		}
+	}
+}
+
+// Channels are the public channels Carlos is in. The private ones fetched along the way are kept for Groups
+func (mattermost *MattermostTransport) Channels() ([]Channel, error) {
+	teamChannels, members, err := mattermost.teamChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	mattermost.mu.Lock()
+	mattermost.listing = &mattermostListing{channels: teamChannels, members: members, fetchedAt: time.Now()}
+	mattermost.mu.Unlock()
+
+	channels := []Channel{}
+	for _, channel := range teamChannels {
+		if channel.Type == "O" {
+			channels = append(channels, Channel{ID: channel.ID, Name: channel.Name, Members: members[channel.ID]})
+		}
+	}
+	return channels, nil
+}
+
+// Groups are the private channels Carlos is in. They come from the channels Channels fetched when it has just
+// been called, as it is when the robot downloads everything, otherwise they are fetched again
+func (mattermost *MattermostTransport) Groups() ([]Group, error) {
+	mattermost.mu.Lock()
+	listing := mattermost.listing
+	mattermost.listing = nil
+	mattermost.mu.Unlock()
+
+	var teamChannels []mattermostChannel
+	var members map[string][]string
+	if listing != nil && time.Since(listing.fetchedAt) < mattermostListingReuse {
+		teamChannels, members = listing.channels, listing.members
+	} else {
+		var err error
+		if teamChannels, members, err = mattermost.teamChannels(); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := []Group{}
+	for _, channel := range teamChannels {
+		if channel.Type == "P" {
+			groups = append(groups, Group{ID: channel.ID, Name: channel.Name, IsGroup: true, Members: members[channel.ID]})
+		}
+	}
+	return groups, nil
+}
+
+// UserGroups are left empty, Mattermost only has them on enterprise servers synced from LDAP
+func (mattermost *MattermostTransport) UserGroups() ([]UserGroup, error) {
+	return nil, nil
+}
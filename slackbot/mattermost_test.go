@@ -0,0 +1,339 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeMattermost is just enough of a Mattermost server for Carlos to sign in, look around, receive the posts
+// pushed to Events and post back
+type fakeMattermost struct {
+	*httptest.Server
+	Events chan string
+
+	mu      sync.Mutex
+	token   string
+	posts   []mattermostPost
+	patches map[string]string
+	directs [][]string
+
+	// memberLookups counts how many times a channel's members were asked for
+	memberLookups int
+}
+
+func newFakeMattermost() *fakeMattermost {
+	fake := &fakeMattermost{Events: make(chan string, 10), patches: make(map[string]string)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v4/websocket", websocket.Handler(func(conn *websocket.Conn) {
+		auth := &mattermostAuthentication{}
+		if err := websocket.JSON.Receive(conn, auth); err != nil {
+			return
+		}
+		fake.mu.Lock()
+		fake.token = auth.Data["token"]
+		fake.mu.Unlock()
+
+		for event := range fake.Events {
+			websocket.Message.Send(conn, event)
+		}
+	}))
+	mux.HandleFunc("/api/v4/", fake.rest)
+
+	fake.Server = httptest.NewServer(mux)
+	return fake
+}
+
+func (fake *fakeMattermost) rest(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer mm-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message": "Invalid or expired session"}`))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v4")
+	body, _ := ioutil.ReadAll(r.Body)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if strings.HasSuffix(path, "/members") {
+		fake.memberLookups++
+	}
+
+	switch {
+	case path == "/users/me":
+		w.Write([]byte(`{"id": "carlosid", "username": "carlos", "is_bot": true}`))
+	case path == "/users":
+		w.Write([]byte(`[
+			{"id": "carlosid", "username": "carlos", "is_bot": true},
+			{"id": "danaid", "username": "dana", "first_name": "Dana", "last_name": "Klassen"},
+			{"id": "samid", "username": "sam.jones"},
+			{"id": "goneid", "username": "gone", "delete_at": 1500000000000}
+		]`))
+	case path == "/users/me/teams":
+		w.Write([]byte(`[{"id": "teamid", "name": "carlos-team"}]`))
+	case path == "/users/me/teams/teamid/channels":
+		w.Write([]byte(`[
+			{"id": "townid", "name": "town-square", "type": "O"},
+			{"id": "secretid", "name": "secret", "type": "P"},
+			{"id": "dmid", "name": "carlosid__danaid", "type": "D"}
+		]`))
+	case path == "/channels/townid/members":
+		w.Write([]byte(`[{"user_id": "carlosid"}, {"user_id": "danaid"}, {"user_id": "samid"}]`))
+	case path == "/channels/secretid/members":
+		w.Write([]byte(`[{"user_id": "carlosid"}, {"user_id": "samid"}]`))
+	case path == "/channels/direct":
+		ids := []string{}
+		json.Unmarshal(body, &ids)
+		fake.directs = append(fake.directs, ids)
+		w.Write([]byte(`{"id": "dm-` + ids[1] + `", "type": "D"}`))
+	case path == "/posts":
+		post := mattermostPost{}
+		json.Unmarshal(body, &post)
+		post.ID = fmt.Sprintf("post%d", len(fake.posts))
+		fake.posts = append(fake.posts, post)
+		json.NewEncoder(w).Encode(post)
+	case strings.HasPrefix(path, "/posts/") && strings.HasSuffix(path, "/patch"):
+		fake.patches[strings.TrimSuffix(strings.TrimPrefix(path, "/posts/"), "/patch")] = string(body)
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not found"}`))
+	}
+}
+
+func (fake *fakeMattermost) Posts() []mattermostPost {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]mattermostPost{}, fake.posts...)
+}
+
+// posted wraps a post the way Mattermost sends it over the websocket
+func posted(post mattermostPost, channelType string) string {
+	encoded, _ := json.Marshal(post)
+	event, _ := json.Marshal(map[string]interface{}{
+		"event": "posted",
+		"data":  map[string]string{"post": string(encoded), "channel_type": channelType},
+	})
+	return string(event)
+}
+
+func mattermostRobot(t *testing.T, fake *fakeMattermost) (*Robot, *MattermostTransport) {
+	mattermost := NewMattermostTransport(fake.URL+"/", "mm-token")
+	robot := NewRobot(mattermost)
+	if err := robot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	robot.DownloadUsersMap()
+	return robot, mattermost
+}
+
+func TestMattermostTransportSignsInAndLooksAround(t *testing.T) {
+	fake := newFakeMattermost()
+	defer fake.Close()
+
+	robot, _ := mattermostRobot(t, fake)
+
+	if robot.ID != "carlosid" || robot.Name != "carlos" {
+		t.Error("Expected to be signed in as carlos got: ", robot.ID, robot.Name)
+	}
+
+	if user := robot.Users["danaid"]; user.Name != "dana" || user.SlackProfile.RealName != "Dana Klassen" {
+		t.Error("Expected dana to be downloaded got: ", user)
+	}
+
+	if !robot.Users["goneid"].Deleted || !robot.Users["carlosid"].IsBot {
+		t.Error("Expected deactivated accounts and bots to be marked")
+	}
+
+	if channel := robot.Channels["townid"]; channel.Name != "town-square" || len(channel.Members) != 3 {
+		t.Error("Expected the public channel with its members got: ", channel)
+	}
+
+	if group := robot.Groups["secretid"]; group.Name != "secret" || len(group.Members) != 2 {
+		t.Error("Expected the private channel as a group got: ", group)
+	}
+
+	if _, ok := robot.Channels["dmid"]; ok {
+		t.Error("Expected direct message channels to be left out")
+	}
+
+	if fake.memberLookups != 2 {
+		t.Error("Expected the members of each channel to be looked up once got: ", fake.memberLookups)
+	}
+}
+
+func TestMattermostTransportTranslatesPosts(t *testing.T) {
+	fake := newFakeMattermost()
+	defer fake.Close()
+	defer close(fake.Events)
+
+	robot, _ := mattermostRobot(t, fake)
+	robot.ListenChan = make(chan Message, 10)
+	robot.Listen()
+
+	fake.Events <- `{"event": "hello", "data": {}}`
+	fake.Events <- posted(mattermostPost{ID: "p1", ChannelID: "dmid", UserID: "danaid", Message: "create poll"}, "D")
+	fake.Events <- posted(mattermostPost{ID: "p2", ChannelID: "townid", UserID: "carlosid", Message: "I said this"}, "O")
+	fake.Events <- posted(mattermostPost{ID: "p3", ChannelID: "townid", UserID: "danaid", Message: "dana joined", Type: "system_join_channel"}, "O")
+	fake.Events <- posted(mattermostPost{ID: "p4", ChannelID: "townid", UserID: "danaid", Message: "@carlos send to @sam.jones, ~secret and @channel but not bob@example.com or @nobody."}, "O")
+	fake.Events <- posted(mattermostPost{ID: "p5", ChannelID: "townid", UserID: "samid", Message: "yes", RootID: "p0"}, "O")
+
+	expected := []Message{
+		{Channel: "dmid", User: "danaid", Text: "create poll", Timestamp: "p1", ChannelType: "im"},
+		{Channel: "townid", User: "danaid", Text: "<@carlosid> send to <@samid>, <#secretid|secret> and <!channel> but not bob@example.com or @nobody.", Timestamp: "p4"},
+		{Channel: "townid", User: "samid", Text: "yes", Timestamp: "p5", ThreadTS: "p0"},
+	}
+
+	for _, want := range expected {
+		select {
+		case msg := <-robot.ListenChan:
+			if msg.Type != "message" || msg.Channel != want.Channel || msg.User != want.User || msg.Text != want.Text ||
+				msg.Timestamp != want.Timestamp || msg.ThreadTS != want.ThreadTS || msg.ChannelType != want.ChannelType {
+				t.Error("Expected ", want, " got: ", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected a message for ", want)
+		}
+	}
+
+	if !(Message{ChannelType: "im", Channel: "dmid"}).isPrivate() {
+		t.Error("Expected a Mattermost direct message to be private")
+	}
+
+	fake.mu.Lock()
+	token := fake.token
+	fake.mu.Unlock()
+	if token != "mm-token" {
+		t.Error("Expected the websocket to be authenticated with the token got: ", token)
+	}
+}
+
+func TestMattermostTransportPosts(t *testing.T) {
+	fake := newFakeMattermost()
+	defer fake.Close()
+
+	robot, _ := mattermostRobot(t, fake)
+
+	if err := robot.SendMessage("danaid", "Thanks <@samid>, see <#townid|town-square> and <!here>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := robot.SendMessage("danaid", "Again"); err != nil {
+		t.Fatal(err)
+	}
+
+	attachment := Attachment{
+		Title:   "Lunch?",
+		Pretext: "Sent to <@danaid>",
+		Fields:  []AttachmentField{{Title: "Creator", Value: "<@samid>"}},
+		Actions: []Action{{Name: "answer", Text: "Yes", Type: "button", Value: "Yes"}},
+	}
+	response, err := robot.postAttachments("townid", "", []Attachment{attachment})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := robot.ReplyInThread("townid", response.TS, "Thanks for answering"); err != nil {
+		t.Fatal(err)
+	}
+
+	attachment.Title = "Lunch? (closed)"
+	if err := robot.UpdateMessage("townid", response.TS, "", attachment); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := fake.Posts()
+	if len(posts) != 4 {
+		t.Fatal("Expected four posts got: ", posts)
+	}
+
+	if posts[0].ChannelID != "dm-danaid" || posts[0].Message != "Thanks @sam.jones, see ~town-square and @here" {
+		t.Error("Expected the message to go to dana's direct channel in Mattermost markup got: ", posts[0])
+	}
+
+	if posts[1].ChannelID != "dm-danaid" || len(fake.directs) != 1 {
+		t.Error("Expected the direct channel to be looked up once got: ", fake.directs)
+	}
+
+	props, _ := json.Marshal(posts[2].Props)
+	if !strings.Contains(string(props), `"pretext":"Sent to @dana"`) || !strings.Contains(string(props), `"value":"@sam.jones"`) {
+		t.Error("Expected the attachment mentions to be translated got: ", string(props))
+	}
+
+	if !strings.Contains(string(props), `"actions":null`) {
+		t.Error("Expected the buttons to be left out got: ", string(props))
+	}
+
+	if response.TS != posts[2].ID || posts[3].RootID != posts[2].ID {
+		t.Error("Expected the reply to be threaded under the post got: ", posts[3])
+	}
+
+	if patch := fake.patches[response.TS]; !strings.Contains(patch, "Lunch? (closed)") {
+		t.Error("Expected the post to be patched got: ", patch)
+	}
+}
+
+func TestMattermostTransportReportsErrors(t *testing.T) {
+	fake := newFakeMattermost()
+	defer fake.Close()
+
+	mattermost := NewMattermostTransport(fake.URL, "wrong")
+	if _, err := mattermost.Connect(); err == nil || !strings.Contains(err.Error(), "Invalid or expired session") {
+		t.Error("Expected the Mattermost error to be passed on got: ", err)
+	}
+}
+
+func TestMattermostPollConversation(t *testing.T) {
+	robot := CleanSetup()
+	fake := newFakeMattermost()
+	defer fake.Close()
+	defer close(fake.Events)
+
+	robot.Transport = NewMattermostTransport(fake.URL, "mm-token")
+	if err := robot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	robot.DownloadUsersMap()
+	robot.ListenChan = make(chan Message, 10)
+	robot.Listen()
+
+	conversation := []string{
+		"create feedback poll",
+		"What's for lunch?",
+		"@sam.jones and ~secret",
+	}
+	for i, text := range conversation {
+		fake.Events <- posted(mattermostPost{ID: fmt.Sprintf("p%d", i), ChannelID: "dmid", UserID: "danaid", Message: text}, "D")
+		select {
+		case msg := <-robot.ListenChan:
+			robot.ProcessMessage(&msg)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected a message for ", text)
+		}
+	}
+
+	poll, err := FindFirstInactivePollByMessage(&Message{User: "danaid", Channel: "dmid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, _ := poll.GetRecipients()
+	if len(recipients) != 1 || recipients[0].SlackID != "samid" {
+		t.Error("Expected the Mattermost user to be the only recipient got: ", recipients)
+	}
+
+	posts := fake.Posts()
+	if len(posts) != 3 || posts[0].ChannelID != "dmid" || !strings.HasPrefix(posts[0].Message, "Creating a feedback poll") {
+		t.Error("Expected Carlos to answer dana in their direct channel got: ", posts)
+	}
+}
@@ -0,0 +1,186 @@
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Post is a rich message posted or updated through the MemoryTransport
+type Post struct {
+	Channel     string
+	TS          string
+	Text        string
+	Attachments []Attachment
+}
+
+// MemoryTransport is a chat platform which only exists in memory. It remembers everything the robot sends and
+// Receive hands the robot messages as if someone had typed them, which is what the tests talk to Carlos through
+type MemoryTransport struct {
+	Self          Identity
+	UserList      []User
+	ChannelList   []Channel
+	GroupList     []Group
+	UserGroupList []UserGroup
+
+	// ConnectErrors are handed out one at a time by Connect before it starts working, like a flaky network
+	ConnectErrors []error
+
+	// ListError makes listing users, channels and groups fail like Slack being unavailable
+	ListError error
+
+	// Unreachable makes posting to these channels fail with the error given, like Slack refusing a message
+	Unreachable map[string]error
+
+	mu      sync.Mutex
+	robot   *Robot
+	sent    []Message
+	posts   []Post
+	updates []Post
+	posted  int
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (memory *MemoryTransport) Connect() (*Identity, error) {
+	if len(memory.ConnectErrors) > 0 {
+		err := memory.ConnectErrors[0]
+		memory.ConnectErrors = memory.ConnectErrors[1:]
+		return nil, err
+	}
+	return &memory.Self, nil
+}
+
+func (memory *MemoryTransport) Listen(robot *Robot) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	memory.robot = robot
+}
+
+// Receive passes the message on to the workers of the robot listening to the transport
+func (memory *MemoryTransport) Receive(msg Message) error {
+	memory.mu.Lock()
+	robot := memory.robot
+	memory.mu.Unlock()
+
+	if robot == nil {
+		return fmt.Errorf("Nothing is listening to the transport")
+	}
+	robot.ListenChan <- msg
+	return nil
+}
+
+func (memory *MemoryTransport) SendMessage(channel, text string) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	memory.sent = append(memory.sent, Message{Type: "message", Channel: channel, Text: text})
+	return nil
+}
+
+func (memory *MemoryTransport) ReplyInThread(channel, threadTS, text string) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	memory.sent = append(memory.sent, Message{Type: "message", Channel: channel, Text: text, ThreadTS: threadTS})
+	return nil
+}
+
+// PostAttachments gives every post its own timestamp so it can be updated like a message posted to Slack
+func (memory *MemoryTransport) PostAttachments(channel, text string, attachments []Attachment) (*PostResponse, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	if err, ok := memory.Unreachable[channel]; ok {
+		return nil, err
+	}
+
+	memory.posted++
+	ts := fmt.Sprintf("1500000000.%06d", memory.posted)
+	memory.posts = append(memory.posts, Post{Channel: channel, TS: ts, Text: text, Attachments: attachments})
+	return &PostResponse{Ok: true, Channel: channel, TS: ts}, nil
+}
+
+func (memory *MemoryTransport) UpdateMessage(channel, ts, text string, attachments []Attachment) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	memory.updates = append(memory.updates, Post{Channel: channel, TS: ts, Text: text, Attachments: attachments})
+	return nil
+}
+
+func (memory *MemoryTransport) Users() ([]User, error) {
+	if memory.ListError != nil {
+		return nil, memory.ListError
+	}
+	return memory.UserList, nil
+}
+
+func (memory *MemoryTransport) Channels() ([]Channel, error) {
+	if memory.ListError != nil {
+		return nil, memory.ListError
+	}
+	return memory.ChannelList, nil
+}
+
+func (memory *MemoryTransport) Groups() ([]Group, error) {
+	if memory.ListError != nil {
+		return nil, memory.ListError
+	}
+	return memory.GroupList, nil
+}
+
+func (memory *MemoryTransport) UserGroups() ([]UserGroup, error) {
+	return memory.UserGroupList, nil
+}
+
+// Sent are the plain messages and thread replies sent since the last Reset
+func (memory *MemoryTransport) Sent() []Message {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	return append([]Message{}, memory.sent...)
+}
+
+// SentText is the text of every message sent since the last Reset run together
+func (memory *MemoryTransport) SentText() string {
+	return strings.Join(memory.SentTexts(), "")
+}
+
+func (memory *MemoryTransport) SentTexts() []string {
+	texts := []string{}
+	for _, msg := range memory.Sent() {
+		texts = append(texts, msg.Text)
+	}
+	return texts
+}
+
+// LastText is the text of the last message sent
+func (memory *MemoryTransport) LastText() string {
+	texts := memory.SentTexts()
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[len(texts)-1]
+}
+
+// Posts are the rich messages posted since the last Reset
+func (memory *MemoryTransport) Posts() []Post {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	return append([]Post{}, memory.posts...)
+}
+
+// Updates are the changes made to posted messages since the last Reset
+func (memory *MemoryTransport) Updates() []Post {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	return append([]Post{}, memory.updates...)
+}
+
+// Reset forgets everything sent so far. Timestamps carry on from where they were so they stay unique
+func (memory *MemoryTransport) Reset() {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	memory.sent = nil
+	memory.posts = nil
+	memory.updates = nil
+}
@@ -0,0 +1,46 @@
+package slackbot
+
+import (
+	"testing"
+)
+
+func TestMemoryTransportConnectsAndDelivers(t *testing.T) {
+	memory := NewMemoryTransport()
+	memory.Self = Identity{ID: "UCARLOS", Name: "carlos"}
+	memory.UserList = []User{{SlackID: "U1", Name: "dana"}}
+	memory.ChannelList = []Channel{{ID: "C1", Name: "team", Members: []string{"U1"}}}
+
+	robot := NewRobot(memory)
+	if err := robot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if robot.ID != "UCARLOS" || robot.Name != "carlos" {
+		t.Error("Expected the robot to take on the identity of the transport got: ", robot.ID, robot.Name)
+	}
+
+	robot.DownloadUsersMap()
+	if robot.Users["U1"].Name != "dana" || robot.Channels["C1"].Name != "team" {
+		t.Error("Expected users and channels to come from the transport got: ", robot.Users, robot.Channels)
+	}
+
+	robot.Listen()
+	if err := memory.Receive(Message{Type: "message", Channel: "D1", User: "U1", Text: "list drafts"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := <-robot.ListenChan; msg.Text != "list drafts" {
+		t.Error("Expected the message to reach the workers got: ", msg)
+	}
+
+	robot.SendMessage("D1", "hello")
+	robot.PostMessage("C1", "poll", Attachment{Title: "Lunch?"})
+	robot.PostMessage("C1", "poll", Attachment{Title: "Dinner?"})
+	if memory.LastText() != "hello" || len(memory.Posts()) != 2 {
+		t.Fatal("Expected the message and posts to be remembered got: ", memory.Sent(), memory.Posts())
+	}
+
+	if first, second := memory.Posts()[0].TS, memory.Posts()[1].TS; first == second {
+		t.Error("Expected every post to get its own timestamp got: ", first)
+	}
+}
@@ -12,6 +12,14 @@ type ResponseRTMSelf struct {
 	Name string `json:"name"`
 }
 
+// ResponseAuthTest tells us who we are signed in as when there is no rtm.start to ask
+type ResponseAuthTest struct {
+	Ok     bool   `json:"ok"`
+	Error  string `json:"error"`
+	UserID string `json:"user_id"`
+	User   string `json:"user"`
+}
+
 type Message struct {
 	ID            uint64   `json:"id"`
 	Type          string   `json:"type"`
@@ -20,9 +28,13 @@ type Message struct {
 	User          string   `json:"user"`
 	Text          string   `json:"text"`
 	Timestamp     string   `json:"ts"`
-	Handled       bool     `json:"-"` // Did message match a handler?
-	DirectMention bool     `json:"-"` // Does message contain a direct mention
-	CaptureGroup  []string `json:"-"` // hold the capture group when a command is matched
+	ThreadTS      string   `json:"thread_ts,omitempty"`    // Set on replies in a thread, it is the ts of the message replied to
+	Handled       bool     `json:"-"`                      // Did message match a handler?
+	DirectMention bool     `json:"-"`                      // Does message contain a direct mention
+	CaptureGroup  []string `json:"-"`                      // hold the capture group when a command is matched
+	URL           string   `json:"url,omitempty"`          // Sent with reconnect_url events
+	ChannelType   string   `json:"channel_type,omitempty"` // Sent with Events API messages, im for direct messages
+	BotID         string   `json:"bot_id,omitempty"`       // Set when the message came from a bot
 }
 
 type Attachment struct {
@@ -48,19 +60,44 @@ type Attachment struct {
 }
 
 type Action struct {
-	Name    string        `json:"name"`
-	Text    string        `json:"text"`
-	Type    string        `json:"type"`
-	Value   string        `json:"value"`
-	Style   string        `json:"style"`
-	Confirm ActionConfirm `json:"confirm"`
+	Name    string         `json:"name"`
+	Text    string         `json:"text"`
+	Type    string         `json:"type"`
+	Value   string         `json:"value"`
+	Style   string         `json:"style"`
+	Confirm *ActionConfirm `json:"confirm,omitempty"`
 }
 
 type ActionConfirm struct {
 	Title       string `json:"title"`
 	Text        string `json:"text"`
 	OkText      string `json:"ok_text"`
-	DismissText string `json:"ok_text"`
+	DismissText string `json:"dismiss_text"`
+}
+
+// ActionPayload is sent to us by Slack when someone clicks a button on one of our messages
+type ActionPayload struct {
+	Type        string        `json:"type"`
+	Actions     []Action      `json:"actions"`
+	CallbackID  string        `json:"callback_id"`
+	Channel     PayloadEntity `json:"channel"`
+	User        PayloadEntity `json:"user"`
+	ActionTS    string        `json:"action_ts"`
+	MessageTS   string        `json:"message_ts"`
+	ResponseURL string        `json:"response_url"`
+}
+
+type PayloadEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ActionResponse is our reply to an ActionPayload. By default it replaces the message the button was clicked on
+type ActionResponse struct {
+	Text            string       `json:"text"`
+	Attachments     []Attachment `json:"attachments,omitempty"`
+	ResponseType    string       `json:"response_type,omitempty"`
+	ReplaceOriginal bool         `json:"replace_original"`
 }
 
 type AttachmentField struct {
@@ -75,3 +112,11 @@ type PostResponse struct {
 	Channel string `json:"channel"`
 	Error   string `json:"error"`
 }
+
+// EventEnvelope is what the Events API posts to us. Event is only set for event callbacks
+type EventEnvelope struct {
+	Type      string  `json:"type"`
+	Challenge string  `json:"challenge"`
+	EventID   string  `json:"event_id"`
+	Event     Message `json:"event"`
+}
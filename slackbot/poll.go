@@ -3,7 +3,9 @@ package slackbot
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dklassen/CarlosTheCurious/uuid"
@@ -11,13 +13,39 @@ import (
 )
 
 const (
-	ResponsePoll = "response"
-	FeedbackPoll = "feedback"
+	ResponsePoll       = "response"
+	FeedbackPoll       = "feedback"
+	MultipleChoicePoll = "multiple"
+	ScalePoll          = "scale"
+
+	// Polls are either sent to every recipient as a direct message or posted once in a channel
+	DirectDelivery  = "direct"
+	ChannelDelivery = "channel"
+
+	maxAttachmentActions = 5
+
+	// The preview lists everyone the poll is going to up to this many
+	maxListedRecipients = 50
 )
 
 var (
-	ErrExistingInactivePoll = errors.New("CarlosTheCurious: Unable to create poll due to partially created existing poll")
-	ErrInvalidPollType      = errors.New("CarlosTheCurious: Invalid poll type must be of response or feedback")
+	ErrInvalidPollType      = errors.New("CarlosTheCurious: Invalid poll type must be of response, multiple, scale or feedback")
+	ErrPollClosed           = errors.New("CarlosTheCurious: Poll is closed and no longer accepting responses")
+	ErrInvalidPollOption    = errors.New("CarlosTheCurious: Unknown poll option")
+	ErrAlreadyAnswered      = errors.New("CarlosTheCurious: Recipient has already answered this anonymous poll")
+	ErrNotRecipient         = errors.New("CarlosTheCurious: Only recipients of the poll can answer it")
+	ErrAnonymousOpenPoll    = errors.New("CarlosTheCurious: Anonymous polls can only be answered by their recipients")
+	ErrNotPollCreator       = errors.New("CarlosTheCurious: Only the creator of a poll can change it")
+	ErrAnonymousChannelPoll = errors.New("CarlosTheCurious: Anonymous polls can not be posted in a channel")
+
+	// Stages a poll goes through while it is still being put together by the creator
+	preActiveStages = []string{StageInitial, StageGetQuestion, StageGetAnswers, StageGetScale, StageGetRecipients, StageGetCloseTime, StageSendPoll}
+
+	// Stages where the poll has been sent and the results can be looked at
+	publishedStages = []string{StageActive, StageClosed}
+
+	// Stages where the results of the poll can still be looked at, archived polls are kept around for this
+	resultStages = []string{StageActive, StageClosed, StageArchived}
 )
 
 type Poll struct {
@@ -26,21 +54,48 @@ type Poll struct {
 	Channel string `gorm:"not null"`
 	Creator string `gorm:"not null"`
 
-	// The creation stage the poll is in initial -> getQuestion -> getRecipient -> Active -> Cancelled or Archived
+	// The stage the poll is in. See pollLifecycle for the stages and how a poll can move between them
 	Stage string
 
-	// The stage that proceeded the current stage.
-	// NOTE:: This is a bit of a hack since I feel like a separate state change table or log would be a better solution
-	// for tracking state changing history. For now we only need to action on the previous state transition
-	// for instance moving from getAnswers -> paused and than continuing
+	// The stage that proceeded the current stage. The full history lives in the poll_transitions table
+	// but it is handy to have the last one at hand when continuing a conversation
 	PreviousStage string
 
-	// Represents the kind of poll this is. Feedback or Response. Feedback polls as for free text responses, while reponse polls
-	// take a list of possible responses
+	// Represents the kind of poll this is. Feedback, Response or Multiple. Feedback polls as for free text responses, while reponse polls
+	// take a list of possible responses. Multiple choice polls let the recipient pick any number of the possible responses
+	// and scale polls are answered with a number
 	Kind string `gorm:"not null"`
 
 	Question string
 
+	// Scale polls are answered with a whole number from ScaleMin to ScaleMax. The labels describe the two ends
+	ScaleMin      int
+	ScaleMax      int
+	ScaleMinLabel string
+	ScaleMaxLabel string
+
+	// Anonymous polls never store who gave which answer. We only remember that a recipient has answered
+	Anonymous bool
+
+	// Open polls take answers from anyone, not just the recipients they were sent to
+	Open bool
+
+	// When set the poll is closed automatically once the deadline passes
+	ClosesAt *time.Time
+
+	// How often recipients who have not answered get nudged. Zero turns automatic reminders off
+	RemindEvery time.Duration
+
+	// Survey questions belong to a survey and are asked in Position order
+	SurveyID *uint
+	Position int
+
+	// Channel polls are posted once in PostChannel rather than sent to each recipient. PostTS is the timestamp
+	// of the posted message, it is how the tallies get updated and threaded replies are matched to the poll
+	Delivery    string
+	PostChannel string
+	PostTS      string
+
 	// We track recipients at the user level. Each recipient is a user
 	Recipients      []Recipient
 	Responses       []PollResponse
@@ -58,6 +113,18 @@ type PollResponse struct {
 	PollID  uint
 	SlackID string
 	Value   string
+
+	// Score is the answer to a scale poll as a number so we can work out stats on it
+	Score *int
+}
+
+// ResponseSelection is one of the answers picked in a multiple choice response. The response itself keeps all of
+// the picked answers joined together so we can show it back to the user
+type ResponseSelection struct {
+	gorm.Model
+	PollID         uint
+	PollResponseID uint
+	Value          string
 }
 
 type Recipient struct {
@@ -65,6 +132,14 @@ type Recipient struct {
 	SlackID   string
 	PollID    uint
 	SlackName string
+
+	// Responded is flipped once the recipient answers. For anonymous polls this is the only link between a
+	// recipient and the poll responses so we never touch the timestamps when setting it
+	Responded bool
+
+	// How many times the recipient has been nudged to answer and when the last nudge went out
+	RemindersSent  int
+	LastRemindedAt *time.Time
 }
 
 func NewRecipient(id string) (*Recipient, error) {
@@ -83,20 +158,195 @@ func NewPoll(kind, creator, channel string) *Poll {
 		Kind:            kind,
 		Creator:         creator,
 		Channel:         channel,
-		PreviousStage:   "initial",
-		Stage:           "initial",
+		PreviousStage:   StageInitial,
+		Stage:           StageInitial,
+		Delivery:        DirectDelivery,
 		PossibleAnswers: []PossibleAnswer{},
 	}
 }
 
+func (poll *Poll) postsInChannel() bool {
+	return poll.Delivery == ChannelDelivery
+}
+
 func (poll *Poll) Save() error {
 	return GetDB().Save(&poll).Error
 }
 
-func (poll *Poll) TransitionTo(nextStage string) error {
+// TransitionTo moves the poll to the next stage if the lifecycle allows it and records who made the change
+func (poll *Poll) TransitionTo(nextStage, actor string) error {
+	if !pollLifecycle.CanTransition(poll.Stage, nextStage) {
+		logrus.WithFields(logrus.Fields{
+			"poll_uuid": poll.UUID,
+			"from":      poll.Stage,
+			"to":        nextStage,
+			"actor":     actor,
+		}).Warn("Rejected poll stage transition")
+		return ErrIllegalTransition
+	}
+	return poll.recordTransition(nextStage, actor)
+}
+
+// transitionWithin makes the same move as TransitionTo as part of a bigger transaction the caller commits
+func (poll *Poll) transitionWithin(tx *gorm.DB, nextStage, actor string) error {
+	if !pollLifecycle.CanTransition(poll.Stage, nextStage) {
+		return ErrIllegalTransition
+	}
+	return poll.saveTransition(tx, nextStage, actor)
+}
+
+// ReturnFromEdit takes the poll back to the stage the creator left to make a change. It only moves forward
+// while the poll is being edited, everything else has to go through TransitionTo
+func (poll *Poll) ReturnFromEdit(actor string) error {
+	if !poll.isEditing() {
+		logrus.WithFields(logrus.Fields{
+			"poll_uuid": poll.UUID,
+			"from":      poll.Stage,
+			"to":        poll.PreviousStage,
+			"actor":     actor,
+		}).Warn("Rejected return from edit")
+		return ErrIllegalTransition
+	}
+	return poll.recordTransition(poll.PreviousStage, actor)
+}
+
+// recordTransition saves the new stage along with the audit log entry for it. If either fails to save the poll
+// is put back the way it was so callers never carry a stage that isn't in the database
+func (poll *Poll) recordTransition(nextStage, actor string) error {
+	previousStage, stage := poll.PreviousStage, poll.Stage
+
+	tx := GetDB().Begin()
+	err := poll.saveTransition(tx, nextStage, actor)
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		poll.PreviousStage, poll.Stage = previousStage, stage
+	}
+	return err
+}
+
+func (poll *Poll) saveTransition(tx *gorm.DB, nextStage, actor string) error {
 	poll.PreviousStage = poll.Stage
 	poll.Stage = nextStage
-	return poll.Save()
+	if err := tx.Save(poll).Error; err != nil {
+		return err
+	}
+
+	transition := &PollTransition{PollID: poll.ID, FromStage: poll.PreviousStage, ToStage: nextStage, Actor: actor}
+	return tx.Create(transition).Error
+}
+
+// undoSend puts a poll which couldn't be delivered back to waiting to be sent. The lifecycle doesn't let an
+// active poll go back but nobody has seen this one, the history keeps both moves
+func (poll *Poll) undoSend(actor string) error {
+	return poll.recordTransition(StageSendPoll, actor)
+}
+
+// isEditing is true when the creator went back to change an earlier part of the poll. PreviousStage is where
+// they came from and where the poll returns to once the change is made
+func (poll *Poll) isEditing() bool {
+	return creationOrder[poll.PreviousStage] > creationOrder[poll.Stage]
+}
+
+// stageBefore is the step the creator filled in before the current one, empty when there is nothing before it
+func (poll *Poll) stageBefore() string {
+	switch poll.Stage {
+	case StageSendPoll:
+		return StageGetCloseTime
+	case StageGetCloseTime:
+		return StageGetRecipients
+	case StageGetRecipients:
+		return poll.answersStage()
+	case StageGetAnswers, StageGetScale:
+		return StageGetQuestion
+	}
+	return ""
+}
+
+// answersStage is where the creator says how the poll is answered, feedback polls are answered with free text
+// so they don't have one
+func (poll *Poll) answersStage() string {
+	switch poll.Kind {
+	case ResponsePoll, MultipleChoicePoll:
+		return StageGetAnswers
+	case ScalePoll:
+		return StageGetScale
+	}
+	return StageGetQuestion
+}
+
+// Clone copies the question, possible answers, recipients and settings into a brand new poll. The copy starts
+// off in the initial stage, it is up to the caller to move it along
+func (poll *Poll) Clone() (*Poll, error) {
+	answers, err := poll.GetAnswers()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := poll.GetRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	clone := NewPoll(poll.Kind, poll.Creator, poll.Channel)
+	clone.Question = poll.Question
+	clone.RemindEvery = poll.RemindEvery
+	clone.Anonymous = poll.Anonymous
+	clone.Open = poll.Open
+	clone.ScaleMin = poll.ScaleMin
+	clone.ScaleMax = poll.ScaleMax
+	clone.ScaleMinLabel = poll.ScaleMinLabel
+	clone.ScaleMaxLabel = poll.ScaleMaxLabel
+	clone.Delivery = poll.Delivery
+	clone.PostChannel = poll.PostChannel
+
+	// Copies stay open for as long as the original poll was given once it was sent, not counting time spent
+	// drafting it
+	if poll.ClosesAt != nil {
+		sentAt, err := poll.sentAt()
+		if err != nil {
+			return nil, err
+		}
+		closesAt := time.Now().Add(poll.ClosesAt.Sub(sentAt))
+		clone.ClosesAt = &closesAt
+	}
+
+	for _, answer := range answers {
+		clone.PossibleAnswers = append(clone.PossibleAnswers, PossibleAnswer{Value: answer.Value})
+	}
+
+	for _, recipient := range recipients {
+		clone.Recipients = append(clone.Recipients, Recipient{SlackID: recipient.SlackID, SlackName: recipient.SlackName})
+	}
+
+	if err := clone.Save(); err != nil {
+		return nil, err
+	}
+	return clone, copyFollowUps(poll, clone)
+}
+
+// IsClosed is true once the poll has been closed or the deadline has passed and the closer has yet to catch up
+func (poll *Poll) IsClosed(now time.Time) bool {
+	if poll.Stage == StageClosed {
+		return true
+	}
+	return poll.ClosesAt != nil && !poll.ClosesAt.After(now)
+}
+
+// Close moves the poll to the closed stage and posts the final results back to the creator
+func (poll *Poll) Close(robot *Robot) error {
+	if err := poll.TransitionTo(StageClosed, systemActor); err != nil {
+		return err
+	}
+
+	if err := refreshPostedPoll(robot, poll); err != nil {
+		logrus.WithField("poll_uuid", poll.UUID).Error("Error updating the posted poll: ", err)
+	}
+	return robot.PostMessage(poll.Channel, fmt.Sprintf("Poll %s has closed. Here are the final results:", poll.UUID), poll.SlackPollSummary())
 }
 
 func (poll *Poll) AddRecipient(recipient Recipient) error {
@@ -106,8 +356,32 @@ func (poll *Poll) AddRecipient(recipient Recipient) error {
 		Append(recipient).Error
 }
 
+// SetRecipients replaces anyone the poll was going to be sent to with the recipients
 func (poll *Poll) SetRecipients(recipients []Recipient) error {
+	return poll.setRecipients(GetDB(), recipients)
+}
+
+func (poll *Poll) setRecipients(db *gorm.DB, recipients []Recipient) error {
+	if err := db.Unscoped().Where("poll_id = ?", poll.ID).Delete(&Recipient{}).Error; err != nil {
+		return err
+	}
+
 	poll.Recipients = recipients
+	return db.Save(&poll).Error
+}
+
+// SetAnswers replaces the possible answers of a poll which hasn't been sent yet. Follow ups hang off the old
+// answers so they go with them
+func (poll *Poll) SetAnswers(answers []PossibleAnswer) error {
+	if err := GetDB().Unscoped().Where("poll_id = ?", poll.ID).Delete(&FollowUpQuestion{}).Error; err != nil {
+		return err
+	}
+
+	if err := GetDB().Unscoped().Where("poll_id = ?", poll.ID).Delete(&PossibleAnswer{}).Error; err != nil {
+		return err
+	}
+
+	poll.PossibleAnswers = answers
 	return poll.Save()
 }
 
@@ -126,7 +400,56 @@ func FindFirstPollByStage(name, stage string) *Poll {
 	return poll
 }
 
+// isBeingCreated is true while the creator is still filling the poll in
+func (poll *Poll) isBeingCreated() bool {
+	for _, stage := range preActiveStages {
+		if poll.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublished is true once the poll has gone out and until it is cancelled or archived
+func (poll *Poll) isPublished() bool {
+	for _, stage := range publishedStages {
+		if poll.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPossibleAnswers is true for the kinds of poll where recipients pick from a list of answers
+func (poll *Poll) HasPossibleAnswers() bool {
+	return poll.Kind == ResponsePoll || poll.Kind == MultipleChoicePoll
+}
+
+// ValidResponse checks the response is one of the possible answers. For multiple choice polls the response is
+// a comma separated list and every answer in it has to be valid
 func ValidResponse(poll *Poll, response string) bool {
+	if poll.Kind == ScalePoll {
+		_, err := poll.parseScore(response)
+		return err == nil
+	}
+
+	if poll.Kind == MultipleChoicePoll {
+		selections := splitSelections(response)
+		if len(selections) == 0 {
+			return false
+		}
+
+		for _, selection := range selections {
+			if !validAnswer(poll, selection) {
+				return false
+			}
+		}
+		return true
+	}
+	return validAnswer(poll, response)
+}
+
+func validAnswer(poll *Poll, response string) bool {
 	rows, _ := GetDB().Raw("SELECT 1 FROM possible_answers WHERE poll_id = ? AND value = ?", poll.ID, response).Rows()
 
 	defer rows.Close()
@@ -142,13 +465,205 @@ func ValidResponse(poll *Poll, response string) bool {
 	return true
 }
 
-func (poll *Poll) AddResponse(userID, responseValue string) error {
-	if poll.Kind == ResponsePoll && !ValidResponse(poll, responseValue) {
-		return fmt.Errorf("Invalid response %s", responseValue)
+// AddResponse records the user's answer to the poll. A user only ever has one answer per poll, answering again
+// replaces the previous answer which is kept soft deleted as history. The previous answer is returned when the
+// user had already answered
+func (poll *Poll) AddResponse(userID, responseValue string) (*PollResponse, error) {
+	if poll.IsClosed(time.Now()) {
+		return nil, ErrPollClosed
 	}
 
-	response := PollResponse{Value: responseValue, SlackID: userID}
-	return GetDB().Model(poll).Association("Responses").Append(response).Error
+	if poll.HasPossibleAnswers() && !ValidResponse(poll, responseValue) {
+		return nil, fmt.Errorf("Invalid response %s", responseValue)
+	}
+
+	response := PollResponse{PollID: poll.ID}
+	selections := []string{}
+	switch poll.Kind {
+	case MultipleChoicePoll:
+		responseValue = poll.NormaliseResponse(responseValue)
+		selections = splitSelections(responseValue)
+	case ScalePoll:
+		score, err := poll.parseScore(responseValue)
+		if err != nil {
+			return nil, err
+		}
+		responseValue = strconv.Itoa(score)
+		response.Score = &score
+	}
+	response.Value = responseValue
+
+	if !poll.Open && FindRecipientByID(poll.ID, userID).ID == 0 {
+		return nil, ErrNotRecipient
+	}
+
+	if poll.Anonymous {
+		return nil, poll.addAnonymousResponse(userID, response, selections)
+	}
+
+	tx := GetDB().Begin()
+	previous := &PollResponse{}
+	tx.Where("poll_id = ? AND slack_id = ?", poll.ID, userID).First(previous)
+	if previous.ID == 0 {
+		previous = nil
+	} else if previous.Value == responseValue {
+		// Same answer as last time so there is nothing to change
+		return previous, tx.Rollback().Error
+	} else if err := tx.Delete(previous).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	response.SlackID = userID
+	if err := tx.Create(&response).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := createSelections(tx, &response, selections); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Model(&Recipient{}).Where("poll_id = ? AND slack_id = ?", poll.ID, userID).UpdateColumn("responded", true).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return previous, tx.Commit().Error
+}
+
+// ResponseHistory is every answer the user has given to the poll oldest first. Only the last one counts
+func (poll *Poll) ResponseHistory(userID string) ([]PollResponse, error) {
+	responses := []PollResponse{}
+	err := GetDB().Unscoped().Where("poll_id = ? AND slack_id = ?", poll.ID, userID).Order("created_at, id").Find(&responses).Error
+	return responses, err
+}
+
+// addAnonymousResponse stores the answer without the user and with the poll's timestamps so the order or time
+// someone answered can't be used to match them back up with their answer.
+//
+// Marking the recipient as responded and storing the answer are committed separately so the two rows never share
+// a transaction id. What is left linkable: the transactions still follow one another, so someone with access
+// to the database who watches it as answers arrive, or reads its logs, can pair them up when nothing else is
+// going on. The anonymity is from the creator and anyone reading the results, not from the database admin
+func (poll *Poll) addAnonymousResponse(userID string, response PollResponse, selections []string) error {
+	// Claiming the answer first means only one of two answers arriving together gets stored
+	claim := GetDB().Model(&Recipient{}).
+		Where("poll_id = ? AND slack_id = ? AND responded = ?", poll.ID, userID, false).
+		UpdateColumn("responded", true)
+	if claim.Error != nil {
+		return claim.Error
+	}
+
+	if claim.RowsAffected == 0 {
+		return ErrAlreadyAnswered
+	}
+
+	response.CreatedAt = poll.CreatedAt
+	response.UpdatedAt = poll.CreatedAt
+
+	tx := GetDB().Begin()
+	err := tx.Create(&response).Error
+	if err == nil {
+		err = createSelections(tx, &response, selections)
+	}
+
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+
+	// Give the recipient their answer back so they can try again
+	if err != nil {
+		GetDB().Model(&Recipient{}).Where("poll_id = ? AND slack_id = ?", poll.ID, userID).UpdateColumn("responded", false)
+	}
+	return err
+}
+
+// createSelections stores each picked answer of a multiple choice response. The selections share the response's
+// timestamps so they give away no more about an anonymous answer than the response does
+func createSelections(tx *gorm.DB, response *PollResponse, selections []string) error {
+	for _, value := range selections {
+		selection := ResponseSelection{PollID: response.PollID, PollResponseID: response.ID, Value: value}
+		selection.CreatedAt = response.CreatedAt
+		selection.UpdatedAt = response.UpdatedAt
+		if err := tx.Create(&selection).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSelections breaks a comma separated multiple choice answer up dropping blanks and repeats
+func splitSelections(response string) []string {
+	selections := []string{}
+	seen := make(map[string]bool)
+	for _, selection := range strings.Split(response, ",") {
+		selection = strings.TrimSpace(selection)
+		if selection == "" || seen[selection] {
+			continue
+		}
+		seen[selection] = true
+		selections = append(selections, selection)
+	}
+	return selections
+}
+
+// toggleSelection adds the answer to the selections or takes it away if it was already picked
+func toggleSelection(selections []string, answer string) []string {
+	toggled := []string{}
+	found := false
+	for _, selection := range selections {
+		if selection == answer {
+			found = true
+			continue
+		}
+		toggled = append(toggled, selection)
+	}
+
+	if !found {
+		toggled = append(toggled, answer)
+	}
+	return toggled
+}
+
+// NormaliseResponse puts the picks of a multiple choice answer in the same order as the possible answers so
+// `b, a` and `a,b` are treated as the same answer. Other kinds of poll are left as they are
+func (poll *Poll) NormaliseResponse(response string) string {
+	if poll.Kind == ScalePoll {
+		return strings.TrimSpace(response)
+	}
+
+	if poll.Kind != MultipleChoicePoll {
+		return response
+	}
+
+	picked := make(map[string]bool)
+	for _, selection := range splitSelections(response) {
+		picked[selection] = true
+	}
+
+	answers, err := poll.GetAnswers()
+	if err != nil {
+		logrus.Error(err)
+		return response
+	}
+
+	selections := []string{}
+	for _, answer := range answers {
+		if picked[answer.Value] {
+			selections = append(selections, answer.Value)
+		}
+	}
+	return strings.Join(selections, ", ")
+}
+
+// CurrentResponse is the user's latest answer to the poll. The response has no ID when they have not answered
+func (poll *Poll) CurrentResponse(userID string) *PollResponse {
+	response := &PollResponse{}
+	GetDB().Where("poll_id = ? AND slack_id = ?", poll.ID, userID).First(response)
+	return response
 }
 
 func (poll *Poll) GetAnswers() ([]PossibleAnswer, error) {
@@ -165,7 +680,7 @@ func (poll *Poll) GetResponses() ([]PollResponse, error) {
 
 func FindFirstPreActivePollByName(name string) (*Poll, error) {
 	poll := &Poll{}
-	GetDB().Where("uuid = ? AND stage != ?", name, "active").First(poll)
+	GetDB().Where("uuid = ? AND stage IN (?)", name, preActiveStages).First(poll)
 
 	if poll.ID == 0 {
 		return poll, fmt.Errorf("No inactive poll with %s found", name)
@@ -175,7 +690,7 @@ func FindFirstPreActivePollByName(name string) (*Poll, error) {
 
 func FindFirstActivePollByUUID(uuid string) (*Poll, error) {
 	poll := &Poll{}
-	GetDB().Where("uuid = ? AND stage = ?", uuid, "active").First(poll)
+	GetDB().Where("uuid = ? AND stage = ?", uuid, StageActive).First(poll)
 
 	if poll.ID == 0 {
 		return poll, fmt.Errorf("No active poll with %s found", uuid)
@@ -183,22 +698,51 @@ func FindFirstActivePollByUUID(uuid string) (*Poll, error) {
 	return poll, nil
 }
 
-func FindFirstActivePollByMessage(msg Message) (*Poll, error) {
+// FindFirstPublishedPollByUUID finds a poll which has been sent out whether or not it has closed
+func FindFirstPublishedPollByUUID(uuid string) (*Poll, error) {
 	poll := &Poll{}
-	GetDB().Where("creator = ? AND channel = ? AND stage = ?", msg.User, msg.Channel, "active").First(&poll)
+	GetDB().Where("uuid = ? AND stage IN (?)", uuid, publishedStages).First(poll)
 
-	if poll.ID != 0 {
-		return poll, fmt.Errorf("No active poll found")
+	if poll.ID == 0 {
+		return poll, fmt.Errorf("No published poll with %s found", uuid)
 	}
 	return poll, nil
 }
 
-func FindFirstInactivePollByMessage(msg *Message) (*Poll, error) {
+// FindPollByThread finds the channel poll posted as the message with the timestamp
+func FindPollByThread(channel, ts string) (*Poll, error) {
 	poll := &Poll{}
-	GetDB().Where("creator = ? AND channel = ? AND stage != ?", msg.User, msg.Channel, "active").First(&poll)
+	GetDB().Where("post_channel = ? AND post_ts = ? AND stage IN (?)", channel, ts, publishedStages).First(poll)
 
 	if poll.ID == 0 {
-		return poll, fmt.Errorf("No poll found")
+		return poll, fmt.Errorf("No published poll posted at %s in %s", ts, channel)
+	}
+	return poll, nil
+}
+
+// FindFirstPollWithResultsByUUID finds a poll which has been sent out including ones that have been archived
+func FindFirstPollWithResultsByUUID(uuid string) (*Poll, error) {
+	poll := &Poll{}
+	GetDB().Where("uuid = ? AND stage IN (?)", uuid, resultStages).First(poll)
+
+	if poll.ID == 0 {
+		return poll, fmt.Errorf("No poll with results for %s found", uuid)
+	}
+	return poll, nil
+}
+
+func FindExpiredPolls(now time.Time) ([]Poll, error) {
+	polls := []Poll{}
+	err := GetDB().Where("stage = ? AND closes_at <= ?", StageActive, now).Find(&polls).Error
+	return polls, err
+}
+
+func FindFirstActivePollByMessage(msg Message) (*Poll, error) {
+	poll := &Poll{}
+	GetDB().Where("creator = ? AND channel = ? AND stage = ?", msg.User, msg.Channel, StageActive).First(&poll)
+
+	if poll.ID != 0 {
+		return poll, fmt.Errorf("No active poll found")
 	}
 	return poll, nil
 }
@@ -237,14 +781,27 @@ func (poll *Poll) slackAnswerString() string {
 	return answerString
 }
 
+// percentOf guards against polls that have nobody to divide by
+func percentOf(count, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return int((float64(count) / float64(total)) * 100)
+}
+
 func responseSummaryField(poll *Poll) *AttachmentField {
 	total := poll.numberOfRecipients()
 	responded := poll.numberOfResponses()
-	responseRatio := (float64(responded) / float64(total)) * 100
+
+	value := fmt.Sprintf("%d%% - %d out of %d", percentOf(responded, total), responded, total)
+	if poll.Open {
+		// Anyone can answer an open poll so comparing against the recipients does not tell us much
+		value = fmt.Sprintf("%d responses - sent to %d", responded, total)
+	}
 
 	return &AttachmentField{
 		Title: "Response Stats:",
-		Value: fmt.Sprintf("%d%% - %d out of %d", int(responseRatio), responded, total),
+		Value: value,
 		Short: false,
 	}
 }
@@ -265,6 +822,62 @@ func recipientsField(poll *Poll) AttachmentField {
 	}
 }
 
+// recipientListField shows exactly who the poll is going to once channels have been expanded and anyone
+// excluded has been left out. Really big lists are cut short to keep the preview readable
+func recipientListField(poll *Poll) AttachmentField {
+	recipients, err := poll.GetRecipients()
+	if err != nil {
+		logrus.Error(err)
+	}
+
+	mentions := []string{}
+	for i, recipient := range recipients {
+		if i == maxListedRecipients {
+			mentions = append(mentions, fmt.Sprintf("and %d more", len(recipients)-maxListedRecipients))
+			break
+		}
+		mentions = append(mentions, fmt.Sprintf("<@%s>", recipient.SlackID))
+	}
+
+	return AttachmentField{
+		Title: "Sending To:",
+		Value: strings.Join(mentions, ", "),
+		Short: false,
+	}
+}
+
+func postChannelField(poll *Poll) AttachmentField {
+	return AttachmentField{
+		Title: "Posted In:",
+		Value: fmt.Sprintf("<#%s>", poll.PostChannel),
+		Short: false,
+	}
+}
+
+func closesAtField(poll *Poll) AttachmentField {
+	return AttachmentField{
+		Title: "Closes At:",
+		Value: poll.ClosesAt.Format(time.RFC1123),
+		Short: false,
+	}
+}
+
+func openField() AttachmentField {
+	return AttachmentField{
+		Title: "Open:",
+		Value: "Anyone can answer, not just the recipients",
+		Short: false,
+	}
+}
+
+func anonymousField() AttachmentField {
+	return AttachmentField{
+		Title: "Anonymous:",
+		Value: "Nobody can see who gave which answer",
+		Short: false,
+	}
+}
+
 func pollTypeField(poll *Poll) AttachmentField {
 	return AttachmentField{
 		Title: "Poll Type:",
@@ -295,10 +908,23 @@ func responseField(poll *Poll) *AttachmentField {
 				  count(a.id) as responses
 	FROM poll_responses as a
 	FULL JOIN possible_answers as b
-	ON a.poll_id = b.poll_id AND a.value = b.value
+	ON a.poll_id = b.poll_id AND a.value = b.value AND a.deleted_at IS NULL
 	WHERE b.poll_id = ?
 	GROUP BY b.value, a.value`
 
+	if poll.Kind == MultipleChoicePoll {
+		// Each respondent can pick several answers so we count the picks on everyone's current response
+		query = `SELECT b.value as possibleAnswer,
+				  count(r.id) as responses
+	FROM possible_answers as b
+	LEFT JOIN response_selections as a
+	ON a.poll_id = b.poll_id AND a.value = b.value AND a.deleted_at IS NULL
+	LEFT JOIN poll_responses as r
+	ON r.id = a.poll_response_id AND r.deleted_at IS NULL
+	WHERE b.poll_id = ?
+	GROUP BY b.value`
+	}
+
 	rows, err := GetDB().Raw(query, poll.ID).Rows()
 	if err != nil {
 		logrus.Panic(err)
@@ -309,19 +935,24 @@ func responseField(poll *Poll) *AttachmentField {
 	var possibleAnswer string
 	var responses int
 
-	totalNumberOfRecipients := poll.numberOfRecipients()
+	// Percentages are out of everyone the poll was sent to, or for open polls out of everyone who answered.
+	// Multiple choice percentages are per respondent so they can add up to more than 100
+	total := poll.numberOfRecipients()
+	if poll.Open {
+		total = poll.numberOfResponses()
+	}
 
 	summary := ""
 	first := true
 	for rows.Next() {
 		rows.Scan(&possibleAnswer, &responses)
-		responsePercent := (float64(responses) / float64(totalNumberOfRecipients)) * 100
+		responsePercent := percentOf(responses, total)
 
 		if first {
 			first = false
-			summary += fmt.Sprintf("%s - %d(%d%%)", possibleAnswer, responses, int(responsePercent))
+			summary += fmt.Sprintf("%s - %d(%d%%)", possibleAnswer, responses, responsePercent)
 		} else {
-			summary += " | " + fmt.Sprintf("%s - %d(%d%%)", possibleAnswer, responses, int(responsePercent))
+			summary += " | " + fmt.Sprintf("%s - %d(%d%%)", possibleAnswer, responses, responsePercent)
 		}
 	}
 
@@ -336,8 +967,16 @@ func responseField(poll *Poll) *AttachmentField {
 func (poll *Poll) SlackPollSummary() Attachment {
 	attachments := []AttachmentField{}
 
-	if poll.Kind == ResponsePoll {
+	if poll.Kind == ScalePoll {
+		scores, err := poll.Scores()
+		if err != nil {
+			logrus.Panic(err)
+		}
+		stats := NewScaleStats(scores, poll.ScaleMin, poll.ScaleMax)
+		attachments = append(attachments, scaleStatsField(stats), scaleHistogramField(poll, stats))
+	} else if poll.HasPossibleAnswers() {
 		attachments = append(attachments, *responseField(poll))
+		attachments = append(attachments, followUpFields(poll)...)
 	} else {
 		responses, err := poll.GetResponses()
 		if err != nil {
@@ -348,6 +987,14 @@ func (poll *Poll) SlackPollSummary() Attachment {
 
 	attachments = append(attachments, *responseSummaryField(poll))
 
+	if poll.Anonymous {
+		attachments = append(attachments, anonymousField())
+	}
+
+	if poll.Open {
+		attachments = append(attachments, openField())
+	}
+
 	title := fmt.Sprintf("%s Results - %s", strings.Title(poll.Kind), poll.UUID)
 	return Attachment{
 		Color:  "#36a64f",
@@ -361,11 +1008,36 @@ func (poll *Poll) SlackPreviewAttachment() Attachment {
 	attachments := []AttachmentField{}
 
 	attachments = append(attachments, recipientsField(poll))
+	attachments = append(attachments, recipientListField(poll))
 
-	if poll.Kind == "response" {
+	if poll.postsInChannel() {
+		attachments = append(attachments, postChannelField(poll))
+	}
+
+	if poll.HasPossibleAnswers() {
 		attachments = append(attachments, possibleAnswerField(poll))
 	}
 
+	if poll.Kind == ScalePoll {
+		attachments = append(attachments, scaleField(poll))
+	}
+
+	if followUps := followUpPreviewField(poll); followUps != nil {
+		attachments = append(attachments, *followUps)
+	}
+
+	if poll.ClosesAt != nil {
+		attachments = append(attachments, closesAtField(poll))
+	}
+
+	if poll.Anonymous {
+		attachments = append(attachments, anonymousField())
+	}
+
+	if poll.Open {
+		attachments = append(attachments, openField())
+	}
+
 	title := fmt.Sprintf("%s Question", strings.Title(poll.Kind))
 	return Attachment{
 		Title:   title,
@@ -375,19 +1047,96 @@ func (poll *Poll) SlackPreviewAttachment() Attachment {
 	}
 }
 
+// answerActions renders each possible answer as a button. Slack only allows a handful of buttons on an
+// attachment so polls with more answers than that have to be answered by typing
+func answerActions(poll *Poll) []Action {
+	answers, err := poll.GetAnswers()
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+
+	if len(answers) > maxAttachmentActions {
+		return nil
+	}
+
+	actions := []Action{}
+	for _, answer := range answers {
+		actions = append(actions, Action{
+			Name:  "answer",
+			Text:  answer.Value,
+			Type:  "button",
+			Value: answer.Value,
+		})
+	}
+	return actions
+}
+
 func (poll *Poll) SlackRecipientAttachment() Attachment {
 	attachments := []AttachmentField{}
 
-	if poll.Kind == ResponsePoll {
+	if poll.HasPossibleAnswers() {
 		attachments = append(attachments, possibleAnswerField(poll))
 	}
 
+	if poll.Kind == ScalePoll {
+		attachments = append(attachments, scaleField(poll))
+	}
+
+	if poll.Anonymous {
+		attachments = append(attachments, anonymousField())
+	}
+
+	answerHint := fmt.Sprintf("You can answer via `answer poll %s {insert response}`", poll.UUID)
+	switch poll.Kind {
+	case MultipleChoicePoll:
+		answerHint = fmt.Sprintf("Pick all that apply. You can answer via `answer poll %s {response}, {response}`", poll.UUID)
+	case ScalePoll:
+		answerHint = fmt.Sprintf("Answer with a number from %d to %d via `answer poll %s {number}`", poll.ScaleMin, poll.ScaleMax, poll.UUID)
+	}
+
 	title := fmt.Sprintf("%s Question", strings.Title(poll.Kind))
-	return Attachment{
-		Pretext: "We have a question for you!",
-		Title:   title,
-		Text:    poll.Question,
-		Fields:  attachments,
-		Color:   "#36a64f",
+	attachment := Attachment{
+		Pretext:  "We have a question for you!",
+		Title:    title,
+		Text:     poll.Question,
+		Fields:   attachments,
+		Color:    "#36a64f",
+		Footer:   answerHint,
+		Fallback: answerHint,
+	}
+
+	// Anonymous answers can't be changed so picking several answers one click at a time does not work for them
+	if poll.Kind == ResponsePoll || (poll.Kind == MultipleChoicePoll && !poll.Anonymous) {
+		attachment.CallbackID = poll.UUID
+		attachment.AttachmentType = "default"
+		attachment.Actions = answerActions(poll)
+	}
+
+	if poll.Kind == ScalePoll {
+		attachment.CallbackID = poll.UUID
+		attachment.AttachmentType = "default"
+		attachment.Actions = scaleActions(poll)
+	}
+	return attachment
+}
+
+// SlackChannelAttachment is a channel poll as posted for everyone to see. The results so far are shown under
+// the question and kept up to date as answers come in. The buttons go once the poll stops taking answers
+func (poll *Poll) SlackChannelAttachment() Attachment {
+	attachment := poll.SlackRecipientAttachment()
+	attachment.Fields = poll.SlackPollSummary().Fields
+
+	if poll.Stage != StageActive {
+		attachment.Actions = nil
+		attachment.Footer = fmt.Sprintf("This poll is %s and no longer taking answers", poll.Stage)
+		return attachment
+	}
+
+	attachment.Footer = "Answer in a threaded reply to this message. " + attachment.Footer
+	if len(attachment.Actions) > 0 {
+		attachment.Footer = "Answer with the buttons or in a threaded reply to this message"
 	}
+	attachment.Fallback = attachment.Footer
+	return attachment
 }
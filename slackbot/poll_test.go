@@ -1,8 +1,11 @@
 package slackbot
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidResponse(t *testing.T) {
@@ -32,6 +35,66 @@ func TestValidResponse(t *testing.T) {
 	if !expectedTrue {
 		t.Fatal("Expected valid response 3 for input")
 	}
+
+	multiple := NewPoll(MultipleChoicePoll, "creatorID", "channelID")
+	multiple.PossibleAnswers = []PossibleAnswer{{Value: "a"}, {Value: "b"}, {Value: "c"}}
+	if err := multiple.Save(); err != nil {
+		t.Fatal("Unable to save poll:", err)
+	}
+
+	var multipleCases = []struct {
+		Response string
+		Expected bool
+	}{
+		{Response: "a", Expected: true},
+		{Response: "c, a", Expected: true},
+		{Response: "a,b,c", Expected: true},
+		{Response: "a, d", Expected: false},
+		{Response: " , ", Expected: false},
+	}
+
+	for _, testCase := range multipleCases {
+		if ValidResponse(multiple, testCase.Response) != testCase.Expected {
+			t.Error("Expected ValidResponse to be", testCase.Expected, "for", testCase.Response)
+		}
+	}
+}
+
+func TestSplitAndToggleSelections(t *testing.T) {
+	var splitCases = []struct {
+		Input    string
+		Expected []string
+	}{
+		{Input: "a", Expected: []string{"a"}},
+		{Input: "a, b ,c", Expected: []string{"a", "b", "c"}},
+		{Input: "a,,a, b", Expected: []string{"a", "b"}},
+		{Input: "", Expected: []string{}},
+	}
+
+	for _, testCase := range splitCases {
+		output := splitSelections(testCase.Input)
+		if strings.Join(output, "|") != strings.Join(testCase.Expected, "|") {
+			t.Error("Expected", testCase.Expected, "got", output, "for", testCase.Input)
+		}
+	}
+
+	var toggleCases = []struct {
+		Selections []string
+		Answer     string
+		Expected   []string
+	}{
+		{Selections: []string{}, Answer: "a", Expected: []string{"a"}},
+		{Selections: []string{"a"}, Answer: "b", Expected: []string{"a", "b"}},
+		{Selections: []string{"a", "b"}, Answer: "a", Expected: []string{"b"}},
+		{Selections: []string{"a"}, Answer: "a", Expected: []string{}},
+	}
+
+	for _, testCase := range toggleCases {
+		output := toggleSelection(testCase.Selections, testCase.Answer)
+		if strings.Join(output, "|") != strings.Join(testCase.Expected, "|") {
+			t.Error("Expected", testCase.Expected, "got", output, "toggling", testCase.Answer)
+		}
+	}
 }
 
 func TestNewRecipient(t *testing.T) {
@@ -70,14 +133,160 @@ func TestNewRecipient(t *testing.T) {
 
 func TestTransitionTo(t *testing.T) {
 	SetupTestDatabase()
-	poll := &Poll{Stage: "stage1", UUID: "1"}
-	poll.TransitionTo("stage2")
-	if strings.Compare(poll.Stage, "stage2") != 0 {
-		t.Fatal("expecting poll to be in stage: stage2, but got:", poll.Stage)
+	poll := &Poll{Stage: StageGetAnswers, UUID: "1"}
+	if err := poll.TransitionTo(StageGetRecipients, "derp"); err != nil {
+		t.Fatal("Unexpected error transitioning poll:", err)
+	}
+
+	if strings.Compare(poll.Stage, StageGetRecipients) != 0 {
+		t.Fatal("expecting poll to be in stage: getRecipients, but got:", poll.Stage)
+	}
+
+	if strings.Compare(poll.PreviousStage, StageGetAnswers) != 0 {
+		t.Fatal("expecting poll to be in stage: getAnswers, but got:", poll.Stage)
+	}
+
+	transitions, err := poll.Transitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transitions) != 1 {
+		t.Fatal("Expected 1 transition to be recorded but got", len(transitions))
 	}
 
-	if strings.Compare(poll.PreviousStage, "stage1") != 0 {
-		t.Fatal("expecting poll to be in stage: stage1, but got:", poll.Stage)
+	transition := transitions[0]
+	if transition.FromStage != StageGetAnswers || transition.ToStage != StageGetRecipients || transition.Actor != "derp" {
+		t.Fatal("Unexpected transition recorded", transition.FromStage, transition.ToStage, transition.Actor)
+	}
+}
+
+func TestCloneGivesCopiesAsLongAsTheSourceHadOnceSent(t *testing.T) {
+	SetupTestDatabase()
+
+	// Drafted over three days and then sent an hour ago to stay open for a day
+	sentAt := time.Now().Add(-time.Hour)
+	closesAt := sentAt.Add(24 * time.Hour)
+	source := &Poll{Kind: FeedbackPoll, UUID: "source", Creator: "derp", Channel: "dorp", Stage: StageActive, Question: "How was your week?", ClosesAt: &closesAt}
+	source.CreatedAt = sentAt.Add(-72 * time.Hour)
+	GetDB().Save(source)
+
+	transition := &PollTransition{PollID: source.ID, FromStage: StageSendPoll, ToStage: StageActive, Actor: "derp"}
+	transition.CreatedAt = sentAt
+	GetDB().Save(transition)
+
+	clone, err := source.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Now().Add(24 * time.Hour)
+	if clone.ClosesAt == nil || clone.ClosesAt.Sub(expected) > time.Minute || expected.Sub(*clone.ClosesAt) > time.Minute {
+		t.Error("Expected the copy to close a day from now got: ", clone.ClosesAt)
+	}
+}
+
+func TestTransitionToRejectsIllegalTransitions(t *testing.T) {
+	SetupTestDatabase()
+	var testingTable = []struct {
+		From string
+		To   string
+	}{
+		{From: StageGetQuestion, To: StageActive},
+		{From: StageGetQuestion, To: StageSendPoll},
+		{From: StageGetQuestion, To: StageGetCloseTime},
+		{From: StageGetAnswers, To: StageGetCloseTime},
+		{From: StageClosed, To: StageActive},
+		{From: StageActive, To: StageGetQuestion},
+		{From: StageCancelled, To: StageGetQuestion},
+		{From: StageArchived, To: StageClosed},
+		{From: "", To: StageGetAnswers},
+	}
+
+	for i, testCase := range testingTable {
+		poll := &Poll{Stage: testCase.From, UUID: fmt.Sprintf("illegal-%d", i)}
+		if err := poll.TransitionTo(testCase.To, "derp"); err != ErrIllegalTransition {
+			t.Fatal("Expected illegal transition from", testCase.From, "to", testCase.To, "but got:", err)
+		}
+
+		if poll.Stage != testCase.From {
+			t.Fatal("Expected poll to stay in", testCase.From, "but got", poll.Stage)
+		}
+	}
+}
+
+func TestTransitionToRestoresStageWhenSavingFails(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := &Poll{Kind: ResponsePoll, UUID: "rollback", Stage: StageGetAnswers, PreviousStage: StageGetQuestion}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without somewhere to record the transition the whole change has to be rolled back
+	GetDB().DropTable(&PollTransition{})
+	defer Migrate()
+
+	if err := poll.TransitionTo(StageGetRecipients, "derp"); err == nil {
+		t.Fatal("Expected the transition to fail")
+	}
+
+	if poll.Stage != StageGetAnswers || poll.PreviousStage != StageGetQuestion {
+		t.Error("Expected the poll to be put back the way it was got:", poll.PreviousStage, poll.Stage)
+	}
+
+	saved := &Poll{}
+	GetDB().Where("uuid = ?", "rollback").First(saved)
+	if saved.Stage != StageGetAnswers {
+		t.Error("Expected the stage change to be rolled back got:", saved.Stage)
+	}
+}
+
+func TestReturnFromEditOnlyWhileEditing(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := &Poll{Kind: ResponsePoll, UUID: "not-editing", Stage: StageGetQuestion, PreviousStage: StageInitial}
+	if err := poll.ReturnFromEdit("derp"); err != ErrIllegalTransition || poll.Stage != StageGetQuestion {
+		t.Fatal("Expected a poll which isn't being edited to stay put but got:", err, poll.Stage)
+	}
+
+	poll = &Poll{Kind: ResponsePoll, UUID: "editing", Stage: StageGetQuestion, PreviousStage: StageSendPoll}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := poll.ReturnFromEdit("derp"); err != nil || poll.Stage != StageSendPoll {
+		t.Fatal("Expected the poll to return to sendPoll but got:", err, poll.Stage)
+	}
+}
+
+func TestStageBeforeAndEditing(t *testing.T) {
+	var testCases = []struct {
+		Kind            string
+		Stage           string
+		PreviousStage   string
+		ExpectedBefore  string
+		ExpectedEditing bool
+	}{
+		{ResponsePoll, StageSendPoll, StageGetCloseTime, StageGetCloseTime, false},
+		{ResponsePoll, StageGetRecipients, StageGetAnswers, StageGetAnswers, false},
+		{ScalePoll, StageGetRecipients, StageGetScale, StageGetScale, false},
+		{FeedbackPoll, StageGetRecipients, StageGetQuestion, StageGetQuestion, false},
+		{MultipleChoicePoll, StageGetAnswers, StageGetQuestion, StageGetQuestion, false},
+		{ResponsePoll, StageGetQuestion, StageSendPoll, "", true},
+		{ResponsePoll, StageGetAnswers, StageGetRecipients, StageGetQuestion, true},
+		{ResponsePoll, StageGetQuestion, StageInitial, "", false},
+	}
+
+	for _, testCase := range testCases {
+		poll := &Poll{Kind: testCase.Kind, Stage: testCase.Stage, PreviousStage: testCase.PreviousStage}
+		if before := poll.stageBefore(); before != testCase.ExpectedBefore {
+			t.Error("Expected the stage before", testCase.Stage, "to be", testCase.ExpectedBefore, "but got", before)
+		}
+
+		if poll.isEditing() != testCase.ExpectedEditing {
+			t.Error("Expected editing to be", testCase.ExpectedEditing, "moving from", testCase.PreviousStage, "to", testCase.Stage)
+		}
 	}
 }
 
@@ -112,6 +321,11 @@ func TestSlackPreviewAttachments(t *testing.T) {
 						Value: "2",
 						Short: true,
 					},
+					AttachmentField{
+						Title: "Sending To:",
+						Value: "<@derp>, <@derp2>",
+						Short: false,
+					},
 					AttachmentField{
 						Title: "Possible Answers:",
 						Value: "1, 2",
@@ -140,6 +354,11 @@ func TestSlackPreviewAttachments(t *testing.T) {
 						Value: "2",
 						Short: true,
 					},
+					AttachmentField{
+						Title: "Sending To:",
+						Value: "<@derp>, <@derp2>",
+						Short: false,
+					},
 				},
 			},
 		},
@@ -181,7 +400,7 @@ func TestAddResponseFailsWhenWrongAnswer(t *testing.T) {
 		t.Fatal("Did not expect there to be an issuing saving poll")
 	}
 
-	if err := input.AddResponse("bloop", "2"); err == nil {
+	if _, err := input.AddResponse("bloop", "2"); err == nil {
 		t.Fatal("Expected invalid response")
 	}
 
@@ -190,12 +409,12 @@ func TestAddResponseFailsWhenWrongAnswer(t *testing.T) {
 func TestAddAndGetSavedResponses(t *testing.T) {
 	SetupTestDatabase()
 
-	input := Poll{UUID: "responsetest"}
+	input := Poll{UUID: "responsetest", Recipients: []Recipient{{SlackID: "bloop"}}}
 	if err := input.Save(); err != nil {
 		t.Fatal("Did not expect there to be an issuing saving poll")
 	}
 
-	if err := input.AddResponse("bloop", "I am a valid response"); err != nil {
+	if _, err := input.AddResponse("bloop", "I am a valid response"); err != nil {
 		t.Fatal("Expected no issue saving response")
 	}
 	output, _ := input.GetResponses()
@@ -257,3 +476,333 @@ func TestResponseSummaryField(t *testing.T) {
 		}
 	}
 }
+
+func TestUnansweredRecipients(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := Poll{
+		UUID:       "remindme",
+		Kind:       FeedbackPoll,
+		Stage:      "active",
+		Recipients: []Recipient{{SlackID: "U1"}, {SlackID: "U2"}, {SlackID: "U3"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal("Did not expect there to be an issue saving poll")
+	}
+
+	if _, err := poll.AddResponse("U2", "all good"); err != nil {
+		t.Fatal("Expected no issue saving response")
+	}
+
+	unanswered, err := poll.UnansweredRecipients()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Sort(BySlackID(unanswered))
+	if len(unanswered) != 2 || unanswered[0].SlackID != "U1" || unanswered[1].SlackID != "U3" {
+		t.Fatal("Expected U1 and U3 to be unanswered got: ", unanswered)
+	}
+}
+
+func TestReminderDue(t *testing.T) {
+	created := time.Date(2017, time.January, 1, 12, 0, 0, 0, time.UTC)
+	reminded := created.Add(24 * time.Hour)
+
+	var testCases = []struct {
+		Recipient Recipient
+		Since     time.Time
+		Now       time.Time
+		Expected  bool
+	}{
+		{
+			Recipient: Recipient{},
+			Since:     created,
+			Now:       created.Add(23 * time.Hour),
+			Expected:  false,
+		},
+		{
+			Recipient: Recipient{},
+			Since:     created,
+			Now:       created.Add(24 * time.Hour),
+			Expected:  true,
+		},
+		{
+			// The interval counts from the recipient's last reminder
+			Recipient: Recipient{LastRemindedAt: &reminded, RemindersSent: 1},
+			Since:     created,
+			Now:       created.Add(36 * time.Hour),
+			Expected:  false,
+		},
+		{
+			// A manual reminder only cares about the last one
+			Recipient: Recipient{},
+			Since:     time.Time{},
+			Now:       created,
+			Expected:  true,
+		},
+		{
+			// Used up all the reminders
+			Recipient: Recipient{LastRemindedAt: &reminded, RemindersSent: maxReminders},
+			Since:     created,
+			Now:       created.Add(96 * time.Hour),
+			Expected:  false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if result := testCase.Recipient.ReminderDue(testCase.Since, 24*time.Hour, testCase.Now); result != testCase.Expected {
+			t.Error("Expected reminder due to be: ", testCase.Expected, " got: ", result, " for ", testCase.Recipient)
+		}
+	}
+}
+
+func TestAnonymousResponsesAreNotLinkedToRecipients(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := Poll{
+		UUID:       "anonymous",
+		Kind:       FeedbackPoll,
+		Stage:      "active",
+		Anonymous:  true,
+		Recipients: []Recipient{{SlackID: "U1"}, {SlackID: "U2"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal("Did not expect there to be an issue saving poll")
+	}
+
+	if _, err := poll.AddResponse("U1", "my secret"); err != nil {
+		t.Fatal("Expected no issue saving response: ", err)
+	}
+
+	if _, err := poll.AddResponse("U1", "changed my mind"); err != ErrAlreadyAnswered {
+		t.Error("Expected second anonymous answer to be rejected got: ", err)
+	}
+
+	if _, err := poll.AddResponse("U3", "not invited"); err != ErrNotRecipient {
+		t.Error("Expected answer from outside the poll to be rejected got: ", err)
+	}
+
+	responses, _ := poll.GetResponses()
+	if len(responses) != 1 {
+		t.Fatal("Expected 1 response got: ", len(responses))
+	}
+
+	response := responses[0]
+	if response.SlackID != "" {
+		t.Error("Expected anonymous response to not store the user got: ", response.SlackID)
+	}
+
+	if !response.CreatedAt.Equal(poll.CreatedAt) || !response.UpdatedAt.Equal(poll.CreatedAt) {
+		t.Error("Expected anonymous response to carry the poll timestamps")
+	}
+
+	recipient := FindRecipientByID(poll.ID, "U1")
+	if !recipient.Responded {
+		t.Error("Expected recipient to be marked as responded")
+	}
+
+	if !recipient.UpdatedAt.Equal(recipient.CreatedAt) {
+		t.Error("Expected marking the recipient as responded to leave the timestamps alone")
+	}
+
+	unanswered, _ := poll.UnansweredRecipients()
+	if len(unanswered) != 1 || unanswered[0].SlackID != "U2" {
+		t.Error("Expected only U2 to be left to answer got: ", unanswered)
+	}
+}
+
+func TestAddResponseChangesExistingAnswer(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := Poll{
+		UUID:            "changeable",
+		Kind:            ResponsePoll,
+		Stage:           "active",
+		PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}},
+		Recipients:      []Recipient{{SlackID: "U1"}, {SlackID: "U2"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal("Did not expect there to be an issue saving poll")
+	}
+
+	var testCases = []struct {
+		User             string
+		Answer           string
+		ExpectedPrevious string
+	}{
+		{User: "U1", Answer: "yes", ExpectedPrevious: ""},
+		{User: "U2", Answer: "yes", ExpectedPrevious: ""},
+		{User: "U1", Answer: "no", ExpectedPrevious: "yes"},
+		{User: "U1", Answer: "yes", ExpectedPrevious: "no"},
+		{User: "U1", Answer: "yes", ExpectedPrevious: "yes"},
+	}
+
+	for _, testCase := range testCases {
+		previous, err := poll.AddResponse(testCase.User, testCase.Answer)
+		if err != nil {
+			t.Fatal("Expected no issue saving response: ", err)
+		}
+
+		previousValue := ""
+		if previous != nil {
+			previousValue = previous.Value
+		}
+
+		if previousValue != testCase.ExpectedPrevious {
+			t.Error("Expected previous answer: ", testCase.ExpectedPrevious, " got: ", previousValue)
+		}
+	}
+
+	if poll.numberOfResponses() != 2 {
+		t.Error("Expected one response per recipient got: ", poll.numberOfResponses())
+	}
+
+	if output := responseField(&poll).Value; output != "no - 0(0%) | yes - 2(100%)" && output != "yes - 2(100%) | no - 0(0%)" {
+		t.Error("Expected only the latest answers to be counted got: ", output)
+	}
+
+	history, _ := poll.ResponseHistory("U1")
+	if len(history) != 3 {
+		t.Fatal("Expected 3 answers in the history got: ", len(history))
+	}
+
+	for k, expected := range []string{"yes", "no", "yes"} {
+		if history[k].Value != expected {
+			t.Error("Expected history answer: ", expected, " got: ", history[k].Value)
+		}
+	}
+}
+
+func TestAddResponseOnlyAcceptsRecipientsUnlessOpen(t *testing.T) {
+	SetupTestDatabase()
+
+	var testCases = []struct {
+		Poll          Poll
+		User          string
+		ExpectedError error
+	}{
+		{
+			Poll:          Poll{UUID: "1", Kind: FeedbackPoll, Recipients: []Recipient{{SlackID: "U1"}}},
+			User:          "U1",
+			ExpectedError: nil,
+		},
+		{
+			Poll:          Poll{UUID: "2", Kind: FeedbackPoll, Recipients: []Recipient{{SlackID: "U1"}}},
+			User:          "U2",
+			ExpectedError: ErrNotRecipient,
+		},
+		{
+			Poll:          Poll{UUID: "3", Kind: FeedbackPoll, Open: true, Recipients: []Recipient{{SlackID: "U1"}}},
+			User:          "U2",
+			ExpectedError: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		GetDB().Save(&testCase.Poll)
+		if _, err := testCase.Poll.AddResponse(testCase.User, "hello"); err != testCase.ExpectedError {
+			t.Error("Expected error: ", testCase.ExpectedError, " got: ", err, " for poll ", testCase.Poll.UUID)
+		}
+	}
+}
+
+func TestOpenPollResponseFieldIsOutOfResponses(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := Poll{
+		UUID:            "open",
+		Kind:            ResponsePoll,
+		Open:            true,
+		PossibleAnswers: []PossibleAnswer{{Value: "Gorp"}},
+		Recipients:      []Recipient{{SlackID: "U1"}, {SlackID: "U2"}, {SlackID: "U3"}, {SlackID: "U4"}},
+		Responses:       []PollResponse{{SlackID: "U5", Value: "Gorp"}, {SlackID: "U6", Value: "Gorp"}},
+	}
+	GetDB().Save(&poll)
+
+	if output := responseField(&poll).Value; output != "Gorp - 2(100%)" {
+		t.Error("Expected attachment field: Gorp - 2(100%) but got: ", output)
+	}
+
+	if output := responseSummaryField(&poll).Value; output != "2 responses - sent to 4" {
+		t.Error("Expected attachment field: 2 responses - sent to 4 but got: ", output)
+	}
+}
+
+func TestMultipleChoiceResponseFieldCountsPerRespondent(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := &Poll{
+		Kind:            MultipleChoicePoll,
+		UUID:            "multiple",
+		Stage:           "active",
+		PossibleAnswers: []PossibleAnswer{{Value: "tea"}, {Value: "coffee"}, {Value: "juice"}},
+		Recipients:      []Recipient{{SlackID: "U1"}, {SlackID: "U2"}, {SlackID: "U3"}, {SlackID: "U4"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	answers := []struct {
+		UserID string
+		Value  string
+	}{
+		{UserID: "U1", Value: "tea, coffee"},
+		{UserID: "U2", Value: "coffee"},
+		{UserID: "U3", Value: "juice,tea"},
+		// U3 changes their mind so only the latest picks count
+		{UserID: "U3", Value: "coffee, juice"},
+	}
+
+	for _, answer := range answers {
+		if _, err := poll.AddResponse(answer.UserID, answer.Value); err != nil {
+			t.Fatal("Unexpected error adding response:", err)
+		}
+	}
+
+	if current := poll.CurrentResponse("U3"); current.Value != "coffee, juice" {
+		t.Error("Expected the answer to be stored in the order of the possible answers got", current.Value)
+	}
+
+	if _, err := poll.AddResponse("U4", "tea, lemonade"); err == nil {
+		t.Error("Expected an answer with an invalid pick to be rejected")
+	}
+
+	output := *responseField(poll)
+	for _, expected := range []string{"tea - 1(25%)", "coffee - 3(75%)", "juice - 1(25%)"} {
+		if !strings.Contains(output.Value, expected) {
+			t.Error("Expected responses to contain", expected, "got", output.Value)
+		}
+	}
+
+	summary := *responseSummaryField(poll)
+	if summary.Value != "75% - 3 out of 4" {
+		t.Error("Expected the summary to count respondents not picks got", summary.Value)
+	}
+}
+
+func TestMigrateMovesInitialDraftsToGetQuestion(t *testing.T) {
+	SetupTestDatabase()
+
+	draft := &Poll{Kind: ResponsePoll, UUID: "old-draft", Creator: "derp", Channel: "dorp", Stage: StageInitial}
+	copied := &Poll{Kind: ResponsePoll, UUID: "copy", Creator: "derp", Channel: "dorp", Stage: StageInitial, Question: "Lunch?"}
+	for _, poll := range []*Poll{draft, copied} {
+		if err := poll.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Migrate()
+
+	found := &Poll{}
+	GetDB().Where("uuid = ?", "old-draft").First(found)
+	if found.Stage != StageGetQuestion {
+		t.Error("Expected the old draft to wait for its question got:", found.Stage)
+	}
+
+	found = &Poll{}
+	GetDB().Where("uuid = ?", "copy").First(found)
+	if found.Stage != StageInitial {
+		t.Error("Expected a copy with a question to be left alone got:", found.Stage)
+	}
+}
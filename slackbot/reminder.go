@@ -0,0 +1,159 @@
+package slackbot
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	reminderInterval = time.Minute
+
+	// The most reminders a recipient will get for a single poll, asking for one by hand included
+	maxReminders = 3
+
+	// Nudging people more often than this is just nagging
+	minReminderEvery = time.Hour
+)
+
+// UnansweredRecipients are the recipients that have yet to respond to the poll. We go off the recipient's
+// responded flag since anonymous poll responses can't be matched back to a recipient
+func (poll *Poll) UnansweredRecipients() ([]Recipient, error) {
+	recipients := []Recipient{}
+	err := GetDB().Where("poll_id = ? AND responded = ?", poll.ID, false).Find(&recipients).Error
+	return recipients, err
+}
+
+// ReminderDue is true when the recipient has reminders left and every has passed since since or since they
+// were last nudged, whichever is later
+func (recipient *Recipient) ReminderDue(since time.Time, every time.Duration, now time.Time) bool {
+	if recipient.RemindersSent >= maxReminders {
+		return false
+	}
+
+	if recipient.LastRemindedAt != nil && recipient.LastRemindedAt.After(since) {
+		since = *recipient.LastRemindedAt
+	}
+	return !now.Before(since.Add(every))
+}
+
+// RecipientsToRemind are the recipients who have not answered and are due a reminder. Automatic reminders
+// wait RemindEvery from when the poll was created, asking for one by hand only holds back people who were
+// nudged within the last minReminderEvery. Either way nobody gets more than maxReminders
+func (poll *Poll) RecipientsToRemind(now time.Time, manual bool) ([]Recipient, error) {
+	unanswered, err := poll.UnansweredRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	since, every := poll.CreatedAt, poll.RemindEvery
+	if manual {
+		since, every = time.Time{}, minReminderEvery
+	}
+
+	due := []Recipient{}
+	for _, recipient := range unanswered {
+		if recipient.ReminderDue(since, every, now) {
+			due = append(due, recipient)
+		}
+	}
+	return due, nil
+}
+
+const channelReminderText = "Just a friendly reminder, we would still love to hear from everyone who has not answered yet!"
+
+func (poll *Poll) SlackReminderAttachment() Attachment {
+	attachment := poll.SlackRecipientAttachment()
+	attachment.Pretext = "Just a friendly reminder, we would still love to hear from you!"
+	return attachment
+}
+
+// Remind nudges the recipients who are due a reminder. A poll posted in a channel gets a single reply in its
+// thread rather than a direct message to everyone in the channel. The report says who was nudged and who
+// couldn't be reached, only the people who got the reminder have it counted against them
+func (poll *Poll) Remind(robot *Robot, now time.Time, manual bool) (*DeliveryReport, error) {
+	report := &DeliveryReport{Failed: make(map[string]error)}
+
+	recipients, err := poll.RecipientsToRemind(now, manual)
+	if err != nil || len(recipients) == 0 {
+		return report, err
+	}
+
+	if poll.postsInChannel() {
+		if poll.PostTS == "" {
+			return report, nil
+		}
+
+		if err := robot.ReplyInThread(poll.PostChannel, poll.PostTS, channelReminderText); err != nil {
+			return report, err
+		}
+
+		for _, recipient := range recipients {
+			report.Delivered = append(report.Delivered, recipient.SlackID)
+		}
+		return report, markReminded(recipients, now)
+	}
+
+	reminded := []Recipient{}
+	for _, recipient := range recipients {
+		if err := robot.PostMessage(recipient.SlackID, "", poll.SlackReminderAttachment()); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"poll_id":   poll.ID,
+				"recipient": recipient.SlackID,
+			}).Error("Unable to deliver poll reminder: ", err)
+			report.Failed[recipient.SlackID] = err
+			continue
+		}
+		report.Delivered = append(report.Delivered, recipient.SlackID)
+		reminded = append(reminded, recipient)
+	}
+
+	if len(reminded) == 0 {
+		return report, nil
+	}
+	return report, markReminded(reminded, now)
+}
+
+// markReminded counts the reminder against each recipient. The timestamps are left alone for the same reason
+// as the responded flag, anonymous polls can't give away when somebody answered
+func markReminded(recipients []Recipient, now time.Time) error {
+	ids := make([]uint, len(recipients))
+	for i, recipient := range recipients {
+		ids[i] = recipient.ID
+	}
+
+	return GetDB().Model(&Recipient{}).Where("id IN (?)", ids).UpdateColumns(map[string]interface{}{
+		"reminders_sent":   gorm.Expr("reminders_sent + 1"),
+		"last_reminded_at": now,
+	}).Error
+}
+
+func sendDueReminders(robot *Robot, now time.Time) {
+	polls := []Poll{}
+	err := GetDB().Where("stage = ? AND remind_every > 0", StageActive).Find(&polls).Error
+	if err != nil {
+		logrus.Error("Unable to load polls needing reminders: ", err)
+		return
+	}
+
+	for _, poll := range polls {
+		if _, err := poll.Remind(robot, now, false); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"poll_id":   poll.ID,
+				"poll_uuid": poll.UUID,
+			}).Error("Error sending poll reminders: ", err)
+		}
+	}
+}
+
+// RunReminders periodically nudges recipients who have not answered their polls
+func RunReminders(robot *Robot) {
+	checkInterval := time.NewTicker(reminderInterval)
+	for {
+		select {
+		case now := <-checkInterval.C:
+			sendDueReminders(robot, now)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package slackbot
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Enough for a 0-10 scale. Anything bigger is hard to answer and the histogram gets silly
+	maxScalePoints = 11
+)
+
+var (
+	scalePrompt = "What is the scale? Something like `1-5`, or `1-5 awful, amazing` to label the ends"
+
+	// e.g `1-5`, `1 to 5` or `0-10 not likely, very likely`
+	scaleRegex = regexp.MustCompile(`^(-?\d+)\s*(?:-|to)\s*(-?\d+)(?:\s+(.+?)\s*,\s*(.+?))?$`)
+)
+
+// scaleSpec is the range and labels the creator gives a scale poll
+type scaleSpec struct {
+	Min      int
+	Max      int
+	MinLabel string
+	MaxLabel string
+}
+
+// parseScale reads the creators reply to the scale prompt
+func parseScale(text string) (*scaleSpec, error) {
+	text = strings.TrimSpace(text)
+	match := scaleRegex.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("Unable to understand %s as a scale", text)
+	}
+
+	min, _ := strconv.Atoi(match[1])
+	max, _ := strconv.Atoi(match[2])
+	if min >= max {
+		return nil, fmt.Errorf("The bottom of the scale %d must be lower than the top %d", min, max)
+	}
+
+	if max-min+1 > maxScalePoints {
+		return nil, fmt.Errorf("The scale can have at most %d points", maxScalePoints)
+	}
+
+	return &scaleSpec{Min: min, Max: max, MinLabel: match[3], MaxLabel: match[4]}, nil
+}
+
+// ErrScoreOutOfRange is the answer to a scale poll not being a whole number on the poll's scale
+type ErrScoreOutOfRange struct {
+	Response string
+	Min      int
+	Max      int
+}
+
+func (err *ErrScoreOutOfRange) Error() string {
+	return fmt.Sprintf("CarlosTheCurious: Invalid response %s, answer with a number from %d to %d", err.Response, err.Min, err.Max)
+}
+
+// parseScore turns an answer to a scale poll into a number making sure it is on the scale
+func (poll *Poll) parseScore(response string) (int, error) {
+	score, err := strconv.Atoi(strings.TrimSpace(response))
+	if err != nil || score < poll.ScaleMin || score > poll.ScaleMax {
+		return 0, &ErrScoreOutOfRange{Response: response, Min: poll.ScaleMin, Max: poll.ScaleMax}
+	}
+	return score, nil
+}
+
+// Scores returns the current answers to a scale poll
+func (poll *Poll) Scores() ([]int, error) {
+	scores := []int{}
+	err := GetDB().Model(&PollResponse{}).Where("poll_id = ? AND score IS NOT NULL", poll.ID).Pluck("score", &scores).Error
+	return scores, err
+}
+
+// ScaleStats summarises the answers to a scale poll
+type ScaleStats struct {
+	Count  int
+	Mean   float64
+	Median float64
+	StdDev float64
+
+	// Histogram is the number of answers for each point on the scale from the bottom up
+	Histogram []int
+}
+
+// NewScaleStats works out the stats for the scores. The standard deviation is of the scores themselves
+// rather than an estimate for everyone who could have answered
+func NewScaleStats(scores []int, min, max int) *ScaleStats {
+	stats := &ScaleStats{Count: len(scores), Histogram: make([]int, max-min+1)}
+	if len(scores) == 0 {
+		return stats
+	}
+
+	sorted := append([]int{}, scores...)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, score := range sorted {
+		sum += score
+		if score >= min && score <= max {
+			stats.Histogram[score-min]++
+		}
+	}
+	stats.Mean = float64(sum) / float64(len(sorted))
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		stats.Median = float64(sorted[middle-1]+sorted[middle]) / 2
+	} else {
+		stats.Median = float64(sorted[middle])
+	}
+
+	variance := 0.0
+	for _, score := range sorted {
+		variance += math.Pow(float64(score)-stats.Mean, 2)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(sorted)))
+	return stats
+}
+
+// scaleLabel shows the point on the scale along with its label if it is one of the labelled ends
+func (poll *Poll) scaleLabel(point int) string {
+	switch {
+	case point == poll.ScaleMin && poll.ScaleMinLabel != "":
+		return fmt.Sprintf("%d (%s)", point, poll.ScaleMinLabel)
+	case point == poll.ScaleMax && poll.ScaleMaxLabel != "":
+		return fmt.Sprintf("%d (%s)", point, poll.ScaleMaxLabel)
+	}
+	return strconv.Itoa(point)
+}
+
+func scaleField(poll *Poll) AttachmentField {
+	return AttachmentField{
+		Title: "Scale:",
+		Value: fmt.Sprintf("%s to %s", poll.scaleLabel(poll.ScaleMin), poll.scaleLabel(poll.ScaleMax)),
+		Short: false,
+	}
+}
+
+func scaleStatsField(stats *ScaleStats) AttachmentField {
+	value := "No answers yet"
+	if stats.Count > 0 {
+		value = fmt.Sprintf("Mean %.2f | Median %.1f | Std Dev %.2f", stats.Mean, stats.Median, stats.StdDev)
+	}
+
+	return AttachmentField{
+		Title: "Stats:",
+		Value: value,
+		Short: false,
+	}
+}
+
+func scaleHistogramField(poll *Poll, stats *ScaleStats) AttachmentField {
+	lines := []string{}
+	for i, count := range stats.Histogram {
+		lines = append(lines, fmt.Sprintf("%s %s %d", poll.scaleLabel(poll.ScaleMin+i), strings.Repeat("█", count), count))
+	}
+
+	return AttachmentField{
+		Title: "Distribution:",
+		Value: strings.Join(lines, "\n"),
+		Short: false,
+	}
+}
+
+// scaleActions gives a button for each point on the scale when they all fit on the message
+func scaleActions(poll *Poll) []Action {
+	if poll.ScaleMax-poll.ScaleMin+1 > maxAttachmentActions {
+		return nil
+	}
+
+	actions := []Action{}
+	for point := poll.ScaleMin; point <= poll.ScaleMax; point++ {
+		actions = append(actions, Action{
+			Name:  "answer",
+			Text:  poll.scaleLabel(point),
+			Type:  "button",
+			Value: strconv.Itoa(point),
+		})
+	}
+	return actions
+}
@@ -0,0 +1,166 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseScale(t *testing.T) {
+	var testCases = []struct {
+		Input         string
+		Expected      scaleSpec
+		ExpectedError bool
+	}{
+		{Input: "1-5", Expected: scaleSpec{Min: 1, Max: 5}},
+		{Input: " 0 to 10 ", Expected: scaleSpec{Min: 0, Max: 10}},
+		{Input: "1 - 5 awful, amazing", Expected: scaleSpec{Min: 1, Max: 5, MinLabel: "awful", MaxLabel: "amazing"}},
+		{Input: "1-5 not at all likely , very likely", Expected: scaleSpec{Min: 1, Max: 5, MinLabel: "not at all likely", MaxLabel: "very likely"}},
+		{Input: "5-1", ExpectedError: true},
+		{Input: "3-3", ExpectedError: true},
+		{Input: "1-100", ExpectedError: true},
+		{Input: "one to five", ExpectedError: true},
+		{Input: "1-5 awful", ExpectedError: true},
+	}
+
+	for _, testCase := range testCases {
+		output, err := parseScale(testCase.Input)
+		if testCase.ExpectedError {
+			if err == nil {
+				t.Error("Expected error parsing", testCase.Input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Error("Unexpected error parsing", testCase.Input, err)
+			continue
+		}
+
+		if *output != testCase.Expected {
+			t.Error("Expected", testCase.Expected, "got", *output, "for", testCase.Input)
+		}
+	}
+}
+
+func TestAnswerErrorMessageExplainsTheScale(t *testing.T) {
+	poll := &Poll{Kind: ScalePoll, ScaleMin: 0, ScaleMax: 10}
+
+	_, err := poll.parseScore("eleventy")
+	expected := "Sorry, answer with a number from 0 to 10"
+	if message := answerErrorMessage(err); message != expected {
+		t.Error("Expected: ", expected, " got: ", message)
+	}
+}
+
+func TestNewScaleStats(t *testing.T) {
+	var testCases = []struct {
+		Scores            []int
+		ExpectedMean      float64
+		ExpectedMedian    float64
+		ExpectedStdDev    float64
+		ExpectedHistogram []int
+	}{
+		{
+			Scores:            []int{},
+			ExpectedHistogram: []int{0, 0, 0, 0, 0},
+		},
+		{
+			Scores:            []int{3},
+			ExpectedMean:      3,
+			ExpectedMedian:    3,
+			ExpectedHistogram: []int{0, 0, 1, 0, 0},
+		},
+		{
+			Scores:            []int{5, 1, 4, 2},
+			ExpectedMean:      3,
+			ExpectedMedian:    3,
+			ExpectedStdDev:    1.5811,
+			ExpectedHistogram: []int{1, 1, 0, 1, 1},
+		},
+		{
+			Scores:            []int{2, 4, 4, 4, 5, 5, 1},
+			ExpectedMean:      3.5714,
+			ExpectedMedian:    4,
+			ExpectedStdDev:    1.3997,
+			ExpectedHistogram: []int{1, 1, 0, 3, 2},
+		},
+	}
+
+	closeTo := func(a, b float64) bool {
+		return a-b < 0.0001 && b-a < 0.0001
+	}
+
+	for _, testCase := range testCases {
+		stats := NewScaleStats(testCase.Scores, 1, 5)
+		if stats.Count != len(testCase.Scores) {
+			t.Error("Expected count", len(testCase.Scores), "got", stats.Count)
+		}
+
+		if !closeTo(stats.Mean, testCase.ExpectedMean) || !closeTo(stats.Median, testCase.ExpectedMedian) || !closeTo(stats.StdDev, testCase.ExpectedStdDev) {
+			t.Error("Unexpected stats for", testCase.Scores, "got mean", stats.Mean, "median", stats.Median, "std dev", stats.StdDev)
+		}
+
+		for i, count := range testCase.ExpectedHistogram {
+			if stats.Histogram[i] != count {
+				t.Error("Expected histogram", testCase.ExpectedHistogram, "got", stats.Histogram)
+				break
+			}
+		}
+	}
+}
+
+func TestScalePollSummary(t *testing.T) {
+	SetupTestDatabase()
+
+	poll := &Poll{
+		Kind:          ScalePoll,
+		UUID:          "scale",
+		Stage:         "active",
+		ScaleMin:      1,
+		ScaleMax:      5,
+		ScaleMinLabel: "awful",
+		ScaleMaxLabel: "amazing",
+		Recipients:    []Recipient{{SlackID: "U1"}, {SlackID: "U2"}, {SlackID: "U3"}},
+	}
+	if err := poll.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []string{"0", "6", "great"} {
+		_, err := poll.AddResponse("U1", invalid)
+		if _, ok := err.(*ErrScoreOutOfRange); !ok {
+			t.Error("Expected answer", invalid, "to be rejected as out of range got:", err)
+		}
+	}
+
+	answers := map[string]string{"U1": "2", "U2": " 5", "U3": "5"}
+	for userID, answer := range answers {
+		if _, err := poll.AddResponse(userID, answer); err != nil {
+			t.Fatal("Unexpected error adding response:", err)
+		}
+	}
+
+	// Changing an answer replaces the old score
+	if _, err := poll.AddResponse("U1", "4"); err != nil {
+		t.Fatal("Unexpected error changing response:", err)
+	}
+
+	scores, err := poll.Scores()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scores) != 3 {
+		t.Fatal("Expected 3 scores got", scores)
+	}
+
+	summary := poll.SlackPollSummary()
+	if summary.Fields[0].Value != "Mean 4.67 | Median 5.0 | Std Dev 0.47" {
+		t.Error("Unexpected stats field", summary.Fields[0].Value)
+	}
+
+	histogram := strings.Split(summary.Fields[1].Value, "\n")
+	if len(histogram) != 5 || histogram[0] != "1 (awful)  0" || histogram[4] != "5 (amazing) ██ 2" {
+		t.Error("Unexpected histogram", summary.Fields[1].Value)
+	}
+}
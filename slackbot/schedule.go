@@ -0,0 +1,145 @@
+package slackbot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// How often the scheduler wakes up to look for schedules that are due. Cron has minute resolution
+	// so there is no point checking more often than this
+	schedulerInterval = time.Minute
+)
+
+// PollSchedule sends a fresh copy of a poll every time the cron expression fires
+type PollSchedule struct {
+	gorm.Model
+	PollID  uint   `gorm:"not null"`
+	Creator string `gorm:"not null"`
+	Channel string `gorm:"not null"`
+	Cron    string `gorm:"not null"`
+
+	NextRunAt time.Time
+	LastRunAt *time.Time
+}
+
+func NewPollSchedule(poll *Poll, expression string, now time.Time) (*PollSchedule, error) {
+	cron, err := ParseCron(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	next := cron.Next(now)
+	if next.IsZero() {
+		return nil, fmt.Errorf("The schedule %s never runs", expression)
+	}
+
+	return &PollSchedule{
+		PollID:    poll.ID,
+		Creator:   poll.Creator,
+		Channel:   poll.Channel,
+		Cron:      cron.Expression,
+		NextRunAt: next,
+	}, nil
+}
+
+func (schedule *PollSchedule) Save() error {
+	return GetDB().Save(schedule).Error
+}
+
+func FindSchedulesByPoll(poll *Poll) ([]PollSchedule, error) {
+	schedules := []PollSchedule{}
+	err := GetDB().Where("poll_id = ?", poll.ID).Find(&schedules).Error
+	return schedules, err
+}
+
+// DeleteSchedulesByPoll stops any more copies of the poll being sent
+func DeleteSchedulesByPoll(poll *Poll) error {
+	return GetDB().Where("poll_id = ?", poll.ID).Delete(&PollSchedule{}).Error
+}
+
+func FindDueSchedules(now time.Time) ([]PollSchedule, error) {
+	schedules := []PollSchedule{}
+	err := GetDB().Where("next_run_at <= ?", now).Order("next_run_at").Find(&schedules).Error
+	return schedules, err
+}
+
+// Run clones the scheduled poll, sends it out and works out when we should run next
+func (schedule *PollSchedule) Run(robot *Robot, now time.Time) error {
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return err
+	}
+
+	// Move the schedule along first so a poll that fails to send is not resent every minute
+	schedule.LastRunAt = &now
+	schedule.NextRunAt = cron.Next(now)
+	if err := schedule.Save(); err != nil {
+		return err
+	}
+
+	source := &Poll{}
+	GetDB().Where("id = ?", schedule.PollID).First(source)
+	if source.ID == 0 {
+		return fmt.Errorf("Unable to find poll %d for schedule %d", schedule.PollID, schedule.ID)
+	}
+
+	// Closed polls keep being copied so a recurring question outlives the first one it was scheduled from. Polls
+	// which were cancelled or archived should have taken their schedules with them, drop any left over
+	if !source.isPublished() {
+		logrus.WithFields(logrus.Fields{
+			"schedule_id": schedule.ID,
+			"poll_uuid":   source.UUID,
+			"stage":       source.Stage,
+		}).Warn("Dropping schedule for a poll which is no longer published")
+		return DeleteSchedulesByPoll(source)
+	}
+
+	poll, err := source.Clone()
+	if err != nil {
+		return err
+	}
+
+	if err := poll.TransitionTo(StageActive, systemActor); err != nil {
+		return err
+	}
+
+	report, err := deliverPoll(robot, poll)
+	if err != nil {
+		return err
+	}
+
+	return robot.SendMessage(schedule.Channel, fmt.Sprintf("Scheduled poll is live you can check in by asking me to `show poll %s`", poll.UUID)+report.Summary())
+}
+
+func runDueSchedules(robot *Robot, now time.Time) {
+	schedules, err := FindDueSchedules(now)
+	if err != nil {
+		logrus.Error("Unable to load poll schedules: ", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if err := schedule.Run(robot, now); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"schedule_id": schedule.ID,
+				"poll_id":     schedule.PollID,
+				"cron":        schedule.Cron,
+			}).Error("Error running poll schedule: ", err)
+		}
+	}
+}
+
+// RunScheduler checks for scheduled polls which are due and sends them out
+func RunScheduler(robot *Robot) {
+	checkInterval := time.NewTicker(schedulerInterval)
+	for {
+		select {
+		case now := <-checkInterval.C:
+			runDueSchedules(robot, now)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	var testCases = []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * FUNDAY",
+	}
+
+	for _, testCase := range testCases {
+		if _, err := ParseCron(testCase); err == nil {
+			t.Error("Expected error parsing cron expression: ", testCase)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Sunday January 1st 2017
+	start := time.Date(2017, time.January, 1, 12, 30, 0, 0, time.UTC)
+
+	var testCases = []struct {
+		Expression string
+		Expected   time.Time
+	}{
+		{
+			Expression: "* * * * *",
+			Expected:   time.Date(2017, time.January, 1, 12, 31, 0, 0, time.UTC),
+		},
+		{
+			// Every Monday morning
+			Expression: "0 9 * * MON",
+			Expected:   time.Date(2017, time.January, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			Expression: "*/15 * * * *",
+			Expected:   time.Date(2017, time.January, 1, 12, 45, 0, 0, time.UTC),
+		},
+		{
+			Expression: "0 9 1,15 * *",
+			Expected:   time.Date(2017, time.January, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// Sunday can be written as 7
+			Expression: "0 13 * * 7",
+			Expected:   time.Date(2017, time.January, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			Expression: "0 9 * * 1-5",
+			Expected:   time.Date(2017, time.January, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// When both day fields are restricted either one can match
+			Expression: "0 0 10 * FRI",
+			Expected:   time.Date(2017, time.January, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Expression: "0 0 29 feb *",
+			Expected:   time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Expression: "0 0 30 2 *",
+			Expected:   time.Time{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		cron, err := ParseCron(testCase.Expression)
+		if err != nil {
+			t.Fatal("Unable to parse cron expression: ", testCase.Expression, err)
+		}
+
+		result := cron.Next(start)
+		if !result.Equal(testCase.Expected) {
+			t.Error("Expected next run of ", testCase.Expression, " to be: ", testCase.Expected, " got: ", result)
+		}
+	}
+}
+
+func TestCronNextFindsLeapDaysAcrossCenturies(t *testing.T) {
+	cron, err := ParseCron("0 0 29 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2100 is not a leap year so the next leap day after 2096 is eight years away
+	start := time.Date(2096, time.March, 1, 0, 0, 0, 0, time.UTC)
+	expected := time.Date(2104, time.February, 29, 0, 0, 0, 0, time.UTC)
+	if result := cron.Next(start); !result.Equal(expected) {
+		t.Error("Expected next run to be: ", expected, " got: ", result)
+	}
+}
+
+func TestScheduleRunClonesPoll(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	source := Poll{
+		Kind:            ResponsePoll,
+		UUID:            "source",
+		Creator:         "derp",
+		Channel:         "dorp",
+		Stage:           "active",
+		Question:        "How was your weekend?",
+		PossibleAnswers: []PossibleAnswer{{Value: "good"}, {Value: "bad"}},
+		Recipients:      []Recipient{{SlackID: "U123"}, {SlackID: "U124"}},
+	}
+	GetDB().Save(&source)
+
+	now := time.Date(2017, time.January, 1, 12, 30, 0, 0, time.UTC)
+	schedule, err := NewPollSchedule(&source, "0 9 * * MON", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := schedule.Run(&robot, now); err != nil {
+		t.Fatal("Was not expecting error running schedule: ", err)
+	}
+
+	clone := &Poll{}
+	GetDB().Where("id != ?", source.ID).First(clone)
+	if clone.Stage != "active" {
+		t.Fatal("Expected cloned poll to be active but got: ", clone.Stage)
+	}
+
+	if clone.UUID == source.UUID {
+		t.Error("Expected cloned poll to have a new uuid")
+	}
+
+	if clone.Question != source.Question {
+		t.Error("Expected question: ", source.Question, " got: ", clone.Question)
+	}
+
+	if clone.numberOfRecipients() != 2 {
+		t.Error("Expected cloned poll to have 2 recipients got: ", clone.numberOfRecipients())
+	}
+
+	answers, _ := clone.GetAnswers()
+	if len(answers) != 2 {
+		t.Error("Expected cloned poll to have 2 possible answers got: ", len(answers))
+	}
+
+	if posts := memory.Posts(); len(posts) != 2 {
+		t.Error("Expected the poll to be posted to 2 recipients got: ", len(posts))
+	}
+
+	expectedNext := time.Date(2017, time.January, 2, 9, 0, 0, 0, time.UTC)
+	if !schedule.NextRunAt.Equal(expectedNext) {
+		t.Error("Expected next run at: ", expectedNext, " got: ", schedule.NextRunAt)
+	}
+
+	expectedMessage := "Scheduled poll is live you can check in by asking me to `show poll " + clone.UUID + "`"
+	if output := memory.SentText(); output != expectedMessage {
+		t.Error("Expected the robot to say: ", expectedMessage, " but got: ", output)
+	}
+}
+
+func TestScheduleRunDropsSchedulesOfArchivedPolls(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	source := Poll{Kind: FeedbackPoll, UUID: "archived", Creator: "derp", Channel: "dorp", Stage: StageArchived, Question: "How was your weekend?"}
+	GetDB().Save(&source)
+
+	now := time.Date(2017, time.January, 1, 12, 30, 0, 0, time.UTC)
+	schedule, err := NewPollSchedule(&source, "0 9 * * MON", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Save()
+
+	if err := schedule.Run(&robot, now); err != nil {
+		t.Fatal("Was not expecting error running schedule: ", err)
+	}
+
+	count := 0
+	GetDB().Model(&Poll{}).Count(&count)
+	if count != 1 {
+		t.Error("Expected no copy of the archived poll got: ", count-1)
+	}
+
+	if schedules, _ := FindSchedulesByPoll(&source); len(schedules) != 0 {
+		t.Error("Expected the schedule to be dropped got: ", schedules)
+	}
+
+	if posts := memory.Posts(); len(posts) != 0 {
+		t.Error("Expected nothing to be sent got: ", posts)
+	}
+}
+
+func TestArchivePollDeletesSchedules(t *testing.T) {
+	robot := CleanSetup()
+
+	poll := Poll{Kind: FeedbackPoll, UUID: "weekly", Creator: "derp", Channel: "dorp", Stage: StageClosed, Question: "How was your week?"}
+	GetDB().Save(&poll)
+
+	schedule, err := NewPollSchedule(&poll, "0 9 * * MON", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Save()
+
+	if err := archivePoll(&robot, &Message{User: "derp", Channel: "dorp"}, []string{"", "weekly"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if schedules, _ := FindSchedulesByPoll(&poll); len(schedules) != 0 {
+		t.Error("Expected archiving to stop the schedule got: ", schedules)
+	}
+}
+
+func TestScheduleRunKeepsCopyingClosedPolls(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	source := Poll{Kind: FeedbackPoll, UUID: "closed", Creator: "derp", Channel: "dorp", Stage: StageClosed, Question: "How was your week?", Recipients: []Recipient{{SlackID: "U123"}}}
+	GetDB().Save(&source)
+
+	now := time.Date(2017, time.January, 1, 12, 30, 0, 0, time.UTC)
+	schedule, err := NewPollSchedule(&source, "0 9 * * MON", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Save()
+
+	if err := schedule.Run(&robot, now); err != nil {
+		t.Fatal("Was not expecting error running schedule: ", err)
+	}
+
+	clone := &Poll{}
+	GetDB().Where("id != ?", source.ID).First(clone)
+	if clone.Stage != StageActive {
+		t.Error("Expected a closed poll to still be copied got stage: ", clone.Stage)
+	}
+
+	if schedules, _ := FindSchedulesByPoll(&source); len(schedules) != 1 {
+		t.Error("Expected the schedule to be kept got: ", schedules)
+	}
+
+	if posts := memory.Posts(); len(posts) != 1 {
+		t.Error("Expected the copy to be posted to 1 recipient got: ", len(posts))
+	}
+}
+
+func TestSchedulePollRejectsSurveyQuestions(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	survey := NewSurvey("team", "derp", "dorp")
+	if err := survey.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	poll, err := survey.AddQuestion(FeedbackPoll, "dorp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll.Question = "How was the quarter?"
+	poll.Stage = StageActive
+	poll.Save()
+
+	robot.Dispatch(&Message{User: "derp", Channel: "dorp", Text: "schedule poll " + poll.UUID + " 0 9 * * MON"})
+
+	expected := "Poll " + poll.UUID + " is a question of a survey, questions of a survey can't be scheduled on their own"
+	if output := memory.SentText(); output != expected {
+		t.Error("Expected the robot to say: ", expected, " but got: ", output)
+	}
+
+	if schedules, _ := FindSchedulesByPoll(poll); len(schedules) != 0 {
+		t.Error("Expected no schedule to be saved got: ", schedules)
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -75,73 +75,96 @@ func (client *SlackWebClient) Do(req *http.Request) (resp *http.Response, err er
 }
 
 type Robot struct {
-	ID         string
-	Name       string
-	Origin     string
-	APIToken   string
-	Users      map[string]User
-	Client     WebClienter // http.Client
-	Handler    *MessageHandler
-	Channels   map[string]Channel
-	Groups     map[string]Group
-	Connection *websocket.Conn
-	ListenChan chan Message
+	ID            string
+	Name          string
+	SigningSecret string
+	Transport     Transport // The chat platform the robot talks to people on
+	Users         map[string]User
+	Handler       *MessageHandler
+	Channels      map[string]Channel
+	Groups        map[string]Group
+	UserGroups    map[string]UserGroup
+	ListenChan    chan Message
+
+	seenEvents *seenEvents
 }
 
 func downloadUserList(token string) (UserList, error) {
+	var userList UserList
+
 	resp, err := http.Get(fmt.Sprintf("https://slack.com/api/users.list?token=%s", token))
 	if err != nil {
-		logrus.Error(err)
+		return userList, err
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.Error(err)
+		return userList, err
 	}
 
-	var userList UserList
 	err = json.Unmarshal(body, &userList)
 
 	return userList, err
 }
 
 func downloadChannelList(token string) (ChannelList, error) {
+	var channelList ChannelList
+
 	resp, err := http.Get(fmt.Sprintf("https://slack.com/api/channels.list?token=%s", token))
 	if err != nil {
-		logrus.Error(err)
+		return channelList, err
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.Error(err)
+		return channelList, err
 	}
 
-	var channelList ChannelList
 	err = json.Unmarshal(body, &channelList)
 
 	return channelList, err
 }
 
 func downloadGroupList(token string) (GroupList, error) {
+	var groupList GroupList
+
 	resp, err := http.Get(fmt.Sprintf("https://slack.com/api/groups.list?token=%s", token))
 	if err != nil {
-		logrus.Error(err)
+		return groupList, err
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.Error(err)
+		return groupList, err
 	}
 
-	var groupList GroupList
 	err = json.Unmarshal(body, &groupList)
 
 	return groupList, err
 }
 
+func downloadUserGroupList(token string) (UserGroupList, error) {
+	var userGroupList UserGroupList
+
+	resp, err := http.Get(fmt.Sprintf("https://slack.com/api/usergroups.list?token=%s&include_users=true", token))
+	if err != nil {
+		return userGroupList, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return userGroupList, err
+	}
+
+	err = json.Unmarshal(body, &userGroupList)
+
+	return userGroupList, err
+}
+
 func (msgHandler *MessageHandler) registerCommand(matchPattern string, h HandlerFunc) {
 	r, err := regexp.Compile(matchPattern)
 
@@ -160,7 +183,7 @@ func (msg Message) isMessage() bool {
 }
 
 func (msg Message) isPrivate() bool {
-	if msg.Channel != "" && strings.HasPrefix(msg.Channel, "D") {
+	if msg.ChannelType == "im" || (msg.Channel != "" && strings.HasPrefix(msg.Channel, "D")) {
 		return true
 	}
 	return false
@@ -186,37 +209,15 @@ var defaultMessageHandler = &MessageHandler{
 	Matcher:  basicMatch,
 }
 
-func NewRobot(origin, token string) *Robot {
+func NewRobot(transport Transport) *Robot {
 	return &Robot{
-		Origin:     origin,
-		APIToken:   token,
+		Transport:  transport,
 		Handler:    defaultMessageHandler,
-		Client:     &SlackWebClient{HTTPClient: &http.Client{}},
 		ListenChan: make(chan Message, 10),
+		seenEvents: newSeenEvents(seenEventsSize),
 	}
 }
 
-func (robot *Robot) SlackConnect() {
-	slackResponse, err := slackStart(robot.APIToken)
-	if err != nil {
-		logrus.Fatal(err)
-	}
-
-	websock, err := websocket.Dial(slackResponse.URL, "", robot.Origin)
-	if err != nil {
-		logrus.Fatal(err)
-	}
-
-	robot.ID = slackResponse.Self.ID
-	robot.Name = slackResponse.Self.Name
-	robot.Connection = websock
-
-	logrus.WithFields(logrus.Fields{
-		"robot_id":   slackResponse.Self.ID,
-		"robot_name": slackResponse.Self.Name,
-	}).Info("Connected to Slack!")
-}
-
 var receiveOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
 	return websocket.JSON.Receive(conn, msg)
 }
@@ -226,76 +227,141 @@ var sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
 }
 
 func sendViaRPC(client WebClienter, token, channel, text string, attachments []Attachment) (*http.Response, error) {
-	a, err := json.Marshal(attachments)
-	if err != nil {
-		logrus.Error("Error PostMessage to slack: ", err)
-	}
+	return chatViaRPC(client, "chat.postMessage", token, channel, text, attachments, nil)
+}
 
-	req, _ := http.NewRequest("GET", "https://slack.com/api/chat.postMessage", nil)
+// updateViaRPC replaces the text and attachments of a message we posted earlier
+func updateViaRPC(client WebClienter, token, channel, ts, text string, attachments []Attachment) (*http.Response, error) {
+	return chatViaRPC(client, "chat.update", token, channel, text, attachments, url.Values{"ts": {ts}})
+}
+
+// replyViaRPC posts the text in the thread of the message with the thread timestamp
+func replyViaRPC(client WebClienter, token, channel, threadTS, text string) (*http.Response, error) {
+	return chatViaRPC(client, "chat.postMessage", token, channel, text, nil, url.Values{"thread_ts": {threadTS}})
+}
+
+func chatViaRPC(client WebClienter, method, token, channel, text string, attachments []Attachment, params url.Values) (*http.Response, error) {
+	req, _ := http.NewRequest("GET", "https://slack.com/api/"+method, nil)
 	q := req.URL.Query()
 	q.Add("token", token)
 	q.Add("channel", channel)
 	q.Add("text", text)
-	q.Add("attachments", string(a))
 	q.Add("as_user", "true")
+
+	if len(attachments) > 0 {
+		a, err := json.Marshal(attachments)
+		if err != nil {
+			logrus.Error("Error PostMessage to slack: ", err)
+		}
+		q.Add("attachments", string(a))
+	}
+
+	for key, values := range params {
+		for _, value := range values {
+			q.Add(key, value)
+		}
+	}
 	req.URL.RawQuery = q.Encode()
 	return client.Do(req)
 }
 
-func (robot *Robot) Listen() {
-	go func() {
-		for {
-			msg := &Message{}
-			err := receiveOverWebsocket(robot.Connection, msg)
-			if err != nil {
-				logrus.Error("Error receiving over websocket: ", err.Error())
-			}
+// Connect signs the robot in to its chat platform
+func (robot *Robot) Connect() error {
+	identity, err := robot.Transport.Connect()
+	if err != nil {
+		return err
+	}
 
-			if !msg.isMessage() {
-				continue
-			}
+	robot.ID = identity.ID
+	robot.Name = identity.Name
+
+	logrus.WithFields(logrus.Fields{
+		"robot_id":   identity.ID,
+		"robot_name": identity.Name,
+	}).Info("Connected and ready to talk!")
+	return nil
+}
+
+// connectUntilReady keeps trying to connect with the same backoff used when a connection drops, so Carlos
+// waits out the chat platform being down at startup instead of exiting. Credentials which are turned down or
+// missing won't fix themselves so those are handed back instead
+func (robot *Robot) connectUntilReady(sleep func(time.Duration)) error {
+	backoff := &Backoff{Min: minReconnectDelay, Max: maxReconnectDelay}
+	for {
+		err := robot.Connect()
+		if err == nil {
+			return nil
+		}
 
-			robot.ListenChan <- *msg
+		if _, ok := err.(*ErrNotAuthorized); ok || err == ErrNoAppToken {
+			return err
 		}
-	}()
+
+		delay := backoff.Next()
+		logrus.WithField("retry_in", delay).Warn("Unable to connect: ", err)
+		sleep(delay)
+	}
+}
+
+// Listen starts passing the messages people send on to the workers
+func (robot *Robot) Listen() {
+	robot.Transport.Listen(robot)
 }
 
 func (robot Robot) SendMessage(channel, msg string) (err error) {
-	message := &Message{
-		ID:      atomic.AddUint64(&counter, 1),
-		Type:    "message",
-		Channel: channel,
-		Text:    msg,
-	}
-	return sendOverWebsocket(robot.Connection, message)
+	return robot.Transport.SendMessage(channel, msg)
 }
 
 func (robot Robot) PostMessage(channel, msg string, attachment Attachment) error {
-	attachments := []Attachment{attachment}
-	resp, err := sendViaRPC(robot.Client, robot.APIToken, channel, msg, attachments)
-	if err != nil {
-		logrus.Error("Error posting to slack api: ", err)
-		return err
-	}
+	return robot.PostAttachments(channel, msg, []Attachment{attachment})
+}
 
+// PostAttachments posts a message made up of several attachments such as the results of a survey
+func (robot Robot) PostAttachments(channel, msg string, attachments []Attachment) error {
+	_, err := robot.postAttachments(channel, msg, attachments)
+	return err
+}
+
+// postAttachments posts the message and returns where it ended up so it can be updated later
+func (robot Robot) postAttachments(channel, msg string, attachments []Attachment) (*PostResponse, error) {
+	return robot.Transport.PostAttachments(channel, msg, attachments)
+}
+
+// UpdateMessage replaces a message we posted earlier, identified by its channel and timestamp
+func (robot Robot) UpdateMessage(channel, ts, msg string, attachment Attachment) error {
+	return robot.Transport.UpdateMessage(channel, ts, msg, []Attachment{attachment})
+}
+
+// ReplyInThread sends a message as a reply to the message with the thread timestamp
+func (robot Robot) ReplyInThread(channel, threadTS, msg string) error {
+	return robot.Transport.ReplyInThread(channel, threadTS, msg)
+}
+
+func readPostResponse(resp *http.Response) (*PostResponse, error) {
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Whatever Slack says when it is rate limiting or having trouble isn't always JSON
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, errors.New("ratelimited")
+	} else if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("Slack answered with status %d", resp.StatusCode)
 	}
 
 	var postResponse PostResponse
 
 	err = json.Unmarshal(body, &postResponse)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !postResponse.Ok {
-		return errors.New(postResponse.Error)
+		return nil, errors.New(postResponse.Error)
 	}
-
-	return nil
+	return &postResponse, nil
 }
 
 func (robot Robot) RegisterCommands(cmds map[string]HandlerFunc) {
@@ -309,8 +375,25 @@ func (robot Robot) match(msg *Message) (cmd *Handler, result []string) {
 }
 
 func (robot Robot) continueConversation(msg *Message) {
-	poll := Poll{}
-	GetDB().Where("creator = ? AND channel = ? AND stage != ? ", msg.User, msg.Channel, "active").First(&poll)
+	poll, _ := FindFirstInactivePollByMessage(msg)
+
+	// Without a poll being put together a direct message may be someone answering a follow up or a survey
+	if poll.ID == 0 && msg.isPrivate() {
+		handled, err := continueFollowUp(&robot, msg)
+		if !handled && err == nil {
+			handled, err = continueSurvey(&robot, msg)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"user":    msg.User,
+				"channel": msg.Channel,
+			}).Error("Error continuing conversation: ", err)
+		}
+
+		if handled {
+			return
+		}
+	}
 
 	nextCmd, ok := stageLookup[poll.Stage]
 	if ok != true {
@@ -323,7 +406,7 @@ func (robot Robot) continueConversation(msg *Message) {
 		return
 	}
 
-	if err := nextCmd(&robot, msg, &poll); err != nil {
+	if err := nextCmd(&robot, msg, poll); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"command":   nextCmd,
 			"user":      msg.User,
@@ -336,6 +419,21 @@ func (robot Robot) continueConversation(msg *Message) {
 }
 
 func (robot *Robot) Dispatch(msg *Message) {
+	// Replies in the thread of a channel poll answer it without having to mention Carlos
+	if msg.ThreadTS != "" && msg.Subtype == "" && !msg.DirectMention && !msg.isPrivate() {
+		handled, err := answerThreadReply(robot, msg)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"user":    msg.User,
+				"channel": msg.Channel,
+			}).Error("Error answering poll from thread: ", err)
+		}
+
+		if handled {
+			return
+		}
+	}
+
 	if msg.DirectMention == true || msg.isPrivate() == true {
 		cmd, captureGroups := robot.match(msg)
 
@@ -343,7 +441,7 @@ func (robot *Robot) Dispatch(msg *Message) {
 			logrus.WithFields(logrus.Fields{
 				"Channel": msg.Channel,
 				"User":    msg.User,
-				"Text":    msg.Text,
+				"Command": cmd.pattern.String(),
 			}).Info("Matched command")
 
 			if err := cmd.handlerFunc(robot, msg, captureGroups); err != nil {
@@ -371,54 +469,90 @@ func (robot *Robot) SlackIDString() string {
 	return "<@" + robot.ID + ">"
 }
 
-func (robot *Robot) DownloadGroups() {
-	groups, _ := downloadGroupList(robot.APIToken)
-	if !groups.Ok {
-		logrus.Fatal("Unable to download channels list from Slack: ", groups.Error)
+// DownloadGroups replaces the private groups the robot knows about. When the download fails the groups from
+// last time are kept
+func (robot *Robot) DownloadGroups() error {
+	groups, err := robot.Transport.Groups()
+	if err != nil {
+		return fmt.Errorf("Unable to download groups list: %s", err)
 	}
 
 	groupMap := make(map[string]Group)
-	for _, group := range groups.Groups {
+	for _, group := range groups {
 		groupMap[group.ID] = group
 	}
 	robot.Groups = groupMap
+	return nil
 }
 
-func (robot *Robot) DownloadChannels() {
-	channels, _ := downloadChannelList(robot.APIToken)
-	if !channels.Ok {
-		logrus.Fatal("Unable to download channels list from Slack: ", channels.Error)
+func (robot *Robot) DownloadChannels() error {
+	channels, err := robot.Transport.Channels()
+	if err != nil {
+		return fmt.Errorf("Unable to download channels list: %s", err)
 	}
 
 	channelMap := make(map[string]Channel)
-	for _, channel := range channels.Channels {
+	for _, channel := range channels {
 		channelMap[channel.ID] = channel
 	}
 	robot.Channels = channelMap
+	return nil
 }
 
-func (robot *Robot) DownloadUsers() {
-	users, _ := downloadUserList(robot.APIToken)
+// DownloadUserGroups is allowed to fail since not every team has user groups or gives Carlos access to them.
+// Mentions of user groups are reported back as unknown instead
+func (robot *Robot) DownloadUserGroups() {
+	userGroups, err := robot.Transport.UserGroups()
+	if err != nil {
+		logrus.Warn("Unable to download user groups list: ", err)
+		return
+	}
+
+	userGroupMap := make(map[string]UserGroup)
+	for _, userGroup := range userGroups {
+		userGroupMap[userGroup.ID] = userGroup
+	}
+	robot.UserGroups = userGroupMap
+}
 
-	if !users.Ok {
-		logrus.Fatal("Unable to download users list from Slack: ", users.Error)
+func (robot *Robot) DownloadUsers() error {
+	users, err := robot.Transport.Users()
+	if err != nil {
+		return fmt.Errorf("Unable to download users list: %s", err)
 	}
+
 	userMap := make(map[string]User)
-	for _, user := range users.Members {
+	for _, user := range users {
 		userMap[user.SlackID] = user
 	}
 	robot.Users = userMap
+	return nil
 }
 
-func (robot *Robot) DownloadUsersMap() {
+// DownloadUsersMap refreshes everything the robot knows about the team. Whatever fails to download keeps what
+// was downloaded last time, the first error is returned so startup can refuse to carry on without it
+func (robot *Robot) DownloadUsersMap() error {
 	logrus.Info("Downloading information from slack")
-	robot.DownloadUsers()
-	robot.DownloadChannels()
-	robot.DownloadGroups()
-	logrus.Info("Finished downloading users, channels, and group information")
+
+	var failed error
+	for _, download := range []func() error{robot.DownloadUsers, robot.DownloadChannels, robot.DownloadGroups} {
+		if err := download(); err != nil {
+			logrus.Error(err)
+			if failed == nil {
+				failed = err
+			}
+		}
+	}
+	robot.DownloadUserGroups()
+
+	if failed != nil {
+		return failed
+	}
+	logrus.Info("Finished downloading users, channels, groups and user group information")
+	return nil
 }
 
-func HerokuServer() {
+func HerokuServer(robot *Robot) {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8000"
@@ -428,6 +562,8 @@ func HerokuServer() {
 	http.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
 		io.WriteString(w, "pong")
 	})
+	http.HandleFunc("/slack/actions", robot.InteractiveHandler)
+	http.HandleFunc("/slack/events", robot.EventsHandler)
 
 	err := http.ListenAndServe(fmt.Sprintf(":%s", port), nil)
 
@@ -453,21 +589,42 @@ func MessageWorker(robot *Robot) {
 	}
 }
 
-func Run(origin, apiToken string, workers int) {
+func Run(conf *Config) {
+	var transport Transport = NewSlackTransport(conf.Transport, conf.Origin, conf.SlackAPIToken, conf.AppToken)
+	if conf.Transport == Mattermost {
+		transport = NewMattermostTransport(conf.MattermostURL, conf.MattermostToken)
+	}
+
+	robot := NewRobot(transport)
+	robot.SigningSecret = conf.SigningSecret
+
+	if conf.Transport == EventsTransport && robot.SigningSecret == "" {
+		logrus.Fatal("The events transport needs a signing secret to verify the events Slack sends")
+	}
+
 	if os.Getenv("PLATFORM") == "HEROKU" {
 		logrus.Info("Heroku Platform detected running webserver and keepalive status ping")
-		go HerokuServer()
+		go HerokuServer(robot)
 		go HerokuPing()
+	} else if robot.SigningSecret != "" {
+		logrus.Info("Signing secret configured running webserver for interactive messages")
+		go HerokuServer(robot)
 	}
 
-	robot := NewRobot(origin, apiToken)
-	robot.SlackConnect()
-	robot.DownloadUsersMap()
+	if err := robot.connectUntilReady(time.Sleep); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := robot.DownloadUsersMap(); err != nil {
+		logrus.Fatal(err)
+	}
 	robot.Listen()
 	robot.RegisterCommands(registeredCommands)
+	go RunScheduler(robot)
+	go RunPollCloser(robot)
+	go RunReminders(robot)
 	logrus.Info("Ready and waiting for messages")
 
-	for w := 0; w < workers; w++ {
+	for w := 0; w < conf.Workers; w++ {
 		go MessageWorker(robot)
 	}
 
@@ -499,8 +656,7 @@ func slackStart(token string) (*ResponseRTMStart, error) {
 	}
 
 	if !startResponse.Ok {
-		err = fmt.Errorf("Slack initialization error: %s", startResponse.Error)
-		return nil, err
+		return nil, slackError("Slack initialization error", startResponse.Error)
 	}
 
 	return &startResponse, nil
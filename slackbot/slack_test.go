@@ -2,24 +2,35 @@ package slackbot
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 // MockHttpClient so we can capture requests and check we called what
 // we think we called
 type MockHTTPClient struct {
 	Requests []http.Request
+
+	// Body is what Slack replies with, a plain ok when left empty
+	Body string
 }
 
 func (client *MockHTTPClient) Do(req *http.Request) (resp *http.Response, err error) {
 	client.Requests = append(client.Requests, *req)
+	body := client.Body
+	if body == "" {
+		body = "{\"ok\": true}"
+	}
+
 	response := &http.Response{
 		StatusCode: 200,
-		Body:       ioutil.NopCloser(bytes.NewReader([]byte("{\"ok\": true}"))),
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
 	}
 
 	return response, nil
@@ -60,10 +71,8 @@ func CleanSetup() Robot {
 	SetupTestDatabase()
 	robot := Robot{
 		ID:         "1",
-		Origin:     "",
-		APIToken:   "",
 		Handler:    defaultMessageHandler,
-		Client:     &MockHTTPClient{},
+		Transport:  NewMemoryTransport(),
 		ListenChan: make(chan Message),
 	}
 	robot.RegisterCommands(registeredCommands)
@@ -71,6 +80,12 @@ func CleanSetup() Robot {
 	return robot
 }
 
+// attachmentsText is the attachments as Slack would be sent them so tests can look for text anywhere in them
+func attachmentsText(attachments []Attachment) string {
+	text, _ := json.Marshal(attachments)
+	return string(text)
+}
+
 func testDispatch(t *testing.T) {
 	robot := CleanSetup()
 	robot.Handler = testHandler
@@ -156,3 +171,60 @@ func TestCommandProcessMessageStripsDirectMentionAndModifiesMessage(t *testing.T
 		}
 	}
 }
+
+func TestDownloadUsersMapKeepsWhatItHadWhenRefreshFails(t *testing.T) {
+	memory := NewMemoryTransport()
+	memory.UserList = []User{{SlackID: "U1", Name: "dana"}}
+	memory.ChannelList = []Channel{{ID: "C1", Name: "team"}}
+
+	robot := NewRobot(memory)
+	if err := robot.DownloadUsersMap(); err != nil {
+		t.Fatal(err)
+	}
+
+	memory.ListError = errors.New("service_unavailable")
+	if err := robot.DownloadUsersMap(); err == nil {
+		t.Error("Expected the failed refresh to be reported")
+	}
+
+	if robot.Users["U1"].Name != "dana" || robot.Channels["C1"].Name != "team" {
+		t.Error("Expected the users and channels from before to be kept got: ", robot.Users, robot.Channels)
+	}
+}
+
+func TestConnectUntilReadyWaitsOutFailures(t *testing.T) {
+	memory := NewMemoryTransport()
+	memory.Self = Identity{ID: "UCARLOS", Name: "carlos"}
+	memory.ConnectErrors = []error{errors.New("dial tcp: i/o timeout"), errors.New("Slack initialization error: fatal_error")}
+
+	waits := []time.Duration{}
+	robot := NewRobot(memory)
+	if err := robot.connectUntilReady(func(delay time.Duration) { waits = append(waits, delay) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if robot.ID != "UCARLOS" {
+		t.Error("Expected the robot to connect in the end got: ", robot.ID)
+	}
+
+	if len(waits) != 2 || waits[0] != minReconnectDelay || waits[1] != 2*minReconnectDelay {
+		t.Error("Expected to back off between attempts got: ", waits)
+	}
+}
+
+func TestConnectUntilReadyGivesUpOnBadCredentials(t *testing.T) {
+	memory := NewMemoryTransport()
+	memory.ConnectErrors = []error{errors.New("dial tcp: i/o timeout"), slackError("Slack initialization error", "invalid_auth")}
+
+	waits := []time.Duration{}
+	robot := NewRobot(memory)
+	err := robot.connectUntilReady(func(delay time.Duration) { waits = append(waits, delay) })
+
+	if _, ok := err.(*ErrNotAuthorized); !ok {
+		t.Error("Expected the turned down token to be handed back got: ", err)
+	}
+
+	if len(waits) != 1 {
+		t.Error("Expected to stop trying once the token was turned down got waits: ", waits)
+	}
+}
@@ -0,0 +1,196 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// SlackTransport is the robot's way into Slack. Messages arrive over the RTM, the Events API or Socket Mode
+// depending on Mode and everything is sent with the Web API, apart from plain messages on the RTM which go
+// over its websocket
+type SlackTransport struct {
+	Mode     string
+	Origin   string
+	APIToken string
+	AppToken string // App level token Socket Mode connects with
+	Client   WebClienter
+
+	// Every copy of the robot shares the transport so a new websocket after reconnecting is seen by everything
+	rtm    *rtmConnection
+	socket *rtmConnection
+}
+
+func NewSlackTransport(mode, origin, token, appToken string) *SlackTransport {
+	return &SlackTransport{
+		Mode:     mode,
+		Origin:   origin,
+		APIToken: token,
+		AppToken: appToken,
+		Client:   NewQueuedWebClient(&SlackWebClient{HTTPClient: &http.Client{}}),
+		rtm:      &rtmConnection{},
+		socket:   &rtmConnection{},
+	}
+}
+
+// usesRTM is whether messages go back and forth over the RTM websocket
+func (slack *SlackTransport) usesRTM() bool {
+	return slack.Mode == "" || slack.Mode == RTMTransport
+}
+
+func (slack *SlackTransport) Connect() (*Identity, error) {
+	switch slack.Mode {
+	case "", RTMTransport:
+		return slack.connectRTM()
+	case EventsTransport:
+		return slack.identify()
+	case SocketModeTransport:
+		identity, err := slack.identify()
+		if err != nil {
+			return nil, err
+		}
+		return identity, slack.connectSocket()
+	}
+	return nil, fmt.Errorf("Unknown transport %q, use %q, %q or %q", slack.Mode, RTMTransport, EventsTransport, SocketModeTransport)
+}
+
+// identify asks Slack who we are signed in as. The RTM tells us when connecting, without it auth.test does
+func (slack *SlackTransport) identify() (*Identity, error) {
+	auth, err := slackAuthTest(slack.APIToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{ID: auth.UserID, Name: auth.User}, nil
+}
+
+// Listen passes messages from Slack on to the workers. The Events API has nothing to listen to since Slack
+// posts the events to EventsHandler
+func (slack *SlackTransport) Listen(robot *Robot) {
+	switch slack.Mode {
+	case "", RTMTransport:
+		slack.listenRTM(robot)
+	case SocketModeTransport:
+		slack.listenSocketMode(robot)
+	}
+}
+
+// listenRTM reads messages from the websocket. When the connection drops it reconnects and carries on
+func (slack *SlackTransport) listenRTM(robot *Robot) {
+	go slack.keepAlive()
+	go func() {
+		for {
+			msg := &Message{}
+			err := receiveOverWebsocket(slack.rtm.get(), msg)
+			if err != nil {
+				logrus.Error("Error receiving over websocket: ", err.Error())
+				if isDisconnect(err) {
+					slack.reconnect()
+				}
+				continue
+			}
+
+			if slack.handleConnectionEvent(msg, time.Now()) {
+				continue
+			}
+
+			if !msg.isMessage() {
+				continue
+			}
+
+			robot.ListenChan <- *msg
+		}
+	}()
+}
+
+func (slack *SlackTransport) SendMessage(channel, text string) error {
+	if !slack.usesRTM() {
+		_, err := slack.PostAttachments(channel, text, nil)
+		return err
+	}
+
+	message := &Message{
+		ID:      atomic.AddUint64(&counter, 1),
+		Type:    "message",
+		Channel: channel,
+		Text:    text,
+	}
+	return sendOverWebsocket(slack.rtm.get(), message)
+}
+
+func (slack *SlackTransport) ReplyInThread(channel, threadTS, text string) error {
+	if !slack.usesRTM() {
+		resp, err := replyViaRPC(slack.Client, slack.APIToken, channel, threadTS, text)
+		if err != nil {
+			logrus.Error("Error replying in thread with slack api: ", err)
+			return err
+		}
+
+		_, err = readPostResponse(resp)
+		return err
+	}
+
+	message := &Message{
+		ID:       atomic.AddUint64(&counter, 1),
+		Type:     "message",
+		Channel:  channel,
+		Text:     text,
+		ThreadTS: threadTS,
+	}
+	return sendOverWebsocket(slack.rtm.get(), message)
+}
+
+func (slack *SlackTransport) PostAttachments(channel, text string, attachments []Attachment) (*PostResponse, error) {
+	resp, err := sendViaRPC(slack.Client, slack.APIToken, channel, text, attachments)
+	if err != nil {
+		logrus.Error("Error posting to slack api: ", err)
+		return nil, err
+	}
+	return readPostResponse(resp)
+}
+
+func (slack *SlackTransport) UpdateMessage(channel, ts, text string, attachments []Attachment) error {
+	resp, err := updateViaRPC(slack.Client, slack.APIToken, channel, ts, text, attachments)
+	if err != nil {
+		logrus.Error("Error updating message with slack api: ", err)
+		return err
+	}
+
+	_, err = readPostResponse(resp)
+	return err
+}
+
+func (slack *SlackTransport) Users() ([]User, error) {
+	users, err := downloadUserList(slack.APIToken)
+	if err == nil && !users.Ok {
+		err = errors.New(users.Error)
+	}
+	return users.Members, err
+}
+
+func (slack *SlackTransport) Channels() ([]Channel, error) {
+	channels, err := downloadChannelList(slack.APIToken)
+	if err == nil && !channels.Ok {
+		err = errors.New(channels.Error)
+	}
+	return channels.Channels, err
+}
+
+func (slack *SlackTransport) Groups() ([]Group, error) {
+	groups, err := downloadGroupList(slack.APIToken)
+	if err == nil && !groups.Ok {
+		err = errors.New(groups.Error)
+	}
+	return groups.Groups, err
+}
+
+func (slack *SlackTransport) UserGroups() ([]UserGroup, error) {
+	userGroups, err := downloadUserGroupList(slack.APIToken)
+	if err == nil && !userGroups.Ok {
+		err = errors.New(userGroups.Error)
+	}
+	return userGroups.UserGroups, err
+}
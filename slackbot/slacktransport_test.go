@@ -0,0 +1,81 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestSlackTransportSendsOverRTM(t *testing.T) {
+	client := &MockHTTPClient{Body: `{"ok": true, "channel": "C1", "ts": "1.0"}`}
+	slack := &SlackTransport{Mode: RTMTransport, Client: client, rtm: &rtmConnection{}}
+
+	sent := []Message{}
+	sendOverWebsocket = func(conn *websocket.Conn, msg *Message) error {
+		sent = append(sent, *msg)
+		return nil
+	}
+
+	slack.SendMessage("D1", "What is the question?")
+	slack.ReplyInThread("C1", "1.0", "Got it")
+	if len(sent) != 2 || sent[0].Text != "What is the question?" || sent[1].ThreadTS != "1.0" {
+		t.Fatal("Expected plain messages to go over the websocket got: ", sent)
+	}
+
+	posted, err := slack.PostAttachments("C1", "Lunch?", []Attachment{{Title: "Where for lunch?"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if posted.TS != "1.0" || posted.Channel != "C1" {
+		t.Error("Expected where the message was posted to be returned got: ", posted)
+	}
+
+	if err := slack.UpdateMessage("C1", "1.0", "Lunch?", []Attachment{{Title: "Where for lunch?"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.Requests) != 2 {
+		t.Fatal("Expected attachments to be sent with the Web API got: ", len(client.Requests))
+	}
+
+	post := client.Requests[0].URL
+	attachments := []Attachment{}
+	json.Unmarshal([]byte(post.Query().Get("attachments")), &attachments)
+	if post.Path != "/api/chat.postMessage" || len(attachments) != 1 || attachments[0].Title != "Where for lunch?" {
+		t.Error("Expected the attachments to be posted with chat.postMessage got: ", post.String())
+	}
+
+	update := client.Requests[1].URL
+	if update.Path != "/api/chat.update" || update.Query().Get("ts") != "1.0" {
+		t.Error("Expected the message to be updated with chat.update got: ", update.String())
+	}
+}
+
+func TestSlackTransportSendsOverWebAPIWithoutRTM(t *testing.T) {
+	client := &MockHTTPClient{}
+	slack := &SlackTransport{Mode: EventsTransport, Client: client}
+
+	if err := slack.SendMessage("D1", "What is the question?"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := slack.ReplyInThread("C1", "1.0", "Got it"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.Requests) != 2 {
+		t.Fatal("Expected both messages to be sent with the Web API got: ", len(client.Requests))
+	}
+
+	sent := client.Requests[0].URL
+	if sent.Path != "/api/chat.postMessage" || sent.Query().Get("channel") != "D1" || sent.Query().Get("text") != "What is the question?" {
+		t.Error("Expected the message to be posted with chat.postMessage got: ", sent.String())
+	}
+
+	reply := client.Requests[1].URL
+	if reply.Path != "/api/chat.postMessage" || reply.Query().Get("thread_ts") != "1.0" {
+		t.Error("Expected the reply to be posted in the thread got: ", reply.String())
+	}
+}
@@ -0,0 +1,203 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"golang.org/x/net/websocket"
+)
+
+// SocketModeTransport receives messages over a Socket Mode websocket which we open, so nothing has to reach
+// us over HTTP. Everything is sent over the Web API
+const SocketModeTransport = "socket"
+
+var ErrNoAppToken = errors.New("CarlosTheCurious: Socket Mode needs an app level token")
+
+// SocketEnvelope wraps everything Slack sends over a Socket Mode connection. Envelopes with an id have to be
+// acknowledged or Slack sends them again
+type SocketEnvelope struct {
+	EnvelopeID string          `json:"envelope_id,omitempty"`
+	Type       string          `json:"type,omitempty"`
+	Reason     string          `json:"reason,omitempty"` // Why Slack is disconnecting us
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+type ResponseConnectionsOpen struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+	URL   string `json:"url"`
+}
+
+var receiveEnvelope = func(conn *websocket.Conn, envelope *SocketEnvelope) error {
+	return websocket.JSON.Receive(conn, envelope)
+}
+
+var sendEnvelope = func(conn *websocket.Conn, envelope *SocketEnvelope) error {
+	return websocket.JSON.Send(conn, envelope)
+}
+
+// openSocketConnection asks Slack for a websocket url to receive events on. Each url is only good for one
+// connection
+func openSocketConnection(client WebClienter, appToken string) (string, error) {
+	req, _ := http.NewRequest("POST", "https://slack.com/api/apps.connections.open", nil)
+	req.Header.Set("Authorization", "Bearer "+appToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var openResponse ResponseConnectionsOpen
+	if err = json.Unmarshal(body, &openResponse); err != nil {
+		return "", err
+	}
+
+	if !openResponse.Ok {
+		return "", slackError("CarlosTheCurious: Unable to open a Socket Mode connection", openResponse.Error)
+	}
+	return openResponse.URL, nil
+}
+
+// connectSocket opens a Socket Mode websocket
+func (slack *SlackTransport) connectSocket() error {
+	if slack.AppToken == "" {
+		return ErrNoAppToken
+	}
+
+	url, err := openSocketConnection(slack.Client, slack.AppToken)
+	if err != nil {
+		return err
+	}
+
+	websock, err := dialWebsocket(url, slack.Origin)
+	if err != nil {
+		return err
+	}
+
+	if slack.socket == nil {
+		slack.socket = &rtmConnection{}
+	}
+	slack.socket.set(websock)
+
+	logrus.Info("Connected to Slack with Socket Mode!")
+	return nil
+}
+
+// reconnectSocket keeps asking for a new Socket Mode connection until one works
+func (slack *SlackTransport) reconnectSocket() {
+	slack.socket.close()
+
+	backoff := &Backoff{Min: minReconnectDelay, Max: maxReconnectDelay}
+	for {
+		err := slack.connectSocket()
+		if err == nil {
+			return
+		}
+
+		delay := backoff.Next()
+		logrus.WithField("retry_in", delay).Warn("Unable to reconnect Socket Mode: ", err)
+		time.Sleep(delay)
+	}
+}
+
+// listenSocketMode passes messages from the Socket Mode connection on to the workers the same way listenRTM
+// does for the RTM
+func (slack *SlackTransport) listenSocketMode(robot *Robot) {
+	go func() {
+		for {
+			envelope := &SocketEnvelope{}
+			err := receiveEnvelope(slack.socket.get(), envelope)
+			if err != nil {
+				logrus.Error("Error receiving over Socket Mode: ", err.Error())
+				if isDisconnect(err) {
+					slack.reconnectSocket()
+				}
+				continue
+			}
+
+			slack.handleEnvelope(robot, envelope)
+		}
+	}()
+}
+
+// handleEnvelope acknowledges the envelope before doing anything with it. Slack only waits a few seconds for
+// the acknowledgement before sending it again
+func (slack *SlackTransport) handleEnvelope(robot *Robot, envelope *SocketEnvelope) {
+	if envelope.EnvelopeID != "" {
+		ack := &SocketEnvelope{EnvelopeID: envelope.EnvelopeID}
+		if err := sendEnvelope(slack.socket.get(), ack); err != nil {
+			logrus.Warn("Unable to acknowledge envelope: ", err)
+		}
+	}
+
+	switch envelope.Type {
+	case "hello":
+		logrus.Info("Socket Mode connection is ready")
+	case "disconnect":
+		// A warning comes a little before Slack asks us to refresh, the connection still works until then
+		if envelope.Reason == "warning" {
+			return
+		}
+		logrus.WithField("reason", envelope.Reason).Info("Slack is closing the Socket Mode connection, reconnecting")
+		slack.reconnectSocket()
+	case "events_api":
+		event := &EventEnvelope{}
+		if err := json.Unmarshal(envelope.Payload, event); err != nil {
+			logrus.Warn("Unable to read event from Socket Mode: ", err)
+			return
+		}
+
+		if msg, ok := robot.eventMessage(event.Event); ok {
+			robot.ListenChan <- msg
+		}
+	case "interactive":
+		payload := &ActionPayload{}
+		if err := json.Unmarshal(envelope.Payload, payload); err != nil {
+			logrus.Warn("Unable to read interactive payload from Socket Mode: ", err)
+			return
+		}
+		go slack.respondToAction(robot, payload)
+	}
+}
+
+// respondToAction answers a button click which came over Socket Mode. There is no HTTP request to reply to so
+// the response goes to the response url instead
+func (slack *SlackTransport) respondToAction(robot *Robot, payload *ActionPayload) {
+	response, after := robot.handleAction(payload)
+	if after != nil {
+		defer after()
+	}
+
+	if response == nil || payload.ResponseURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		logrus.Error("Unable to encode action response: ", err)
+		return
+	}
+
+	req, _ := http.NewRequest("POST", payload.ResponseURL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := slack.Client.Do(req)
+	if err != nil {
+		logrus.Error("Unable to respond to action: ", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,117 @@
+package slackbot
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeSocketMode is a Socket Mode server which sends each connection the next list of envelopes and records
+// the acknowledgements it gets back
+func fakeSocketMode(connections [][]string) (*httptest.Server, chan string) {
+	acks := make(chan string, 10)
+	opened := make(chan int, len(connections))
+	for i := range connections {
+		opened <- i
+	}
+
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		envelopes := connections[<-opened]
+		for _, envelope := range envelopes {
+			websocket.Message.Send(conn, envelope)
+		}
+
+		for {
+			ack := &SocketEnvelope{}
+			if err := websocket.JSON.Receive(conn, ack); err != nil {
+				return
+			}
+			acks <- ack.EnvelopeID
+		}
+	}))
+	return server, acks
+}
+
+func socketModeRobot(server *httptest.Server) (*Robot, *SlackTransport, *MockHTTPClient) {
+	client := &MockHTTPClient{Body: `{"ok": true, "url": "` + strings.Replace(server.URL, "http", "ws", 1) + `"}`}
+	slack := &SlackTransport{
+		Mode:     SocketModeTransport,
+		Origin:   server.URL,
+		AppToken: "xapp-1-test",
+		Client:   client,
+		socket:   &rtmConnection{},
+	}
+
+	robot := &Robot{ID: "UCARLOS", Transport: slack, ListenChan: make(chan Message, 10)}
+	return robot, slack, client
+}
+
+func TestSocketModeDeliversMessagesAndAcknowledges(t *testing.T) {
+	server, acks := fakeSocketMode([][]string{{
+		`{"type": "hello"}`,
+		`{"envelope_id": "e1", "type": "events_api", "payload": {"type": "event_callback", "event": {"type": "message", "channel_type": "im", "channel": "D1", "user": "U1", "text": "create poll"}}}`,
+	}})
+	defer server.Close()
+
+	robot, slack, client := socketModeRobot(server)
+	if err := slack.connectSocket(); err != nil {
+		t.Fatal(err)
+	}
+	robot.Listen()
+
+	select {
+	case msg := <-robot.ListenChan:
+		if msg.Channel != "D1" || msg.User != "U1" || msg.Text != "create poll" {
+			t.Error("Expected the direct message to be passed on got: ", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the message to be passed on to the workers")
+	}
+
+	select {
+	case ack := <-acks:
+		if ack != "e1" {
+			t.Error("Expected envelope e1 to be acknowledged got: ", ack)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the envelope to be acknowledged")
+	}
+
+	open := client.Requests[0]
+	if open.URL.Path != "/api/apps.connections.open" || open.Header.Get("Authorization") != "Bearer xapp-1-test" {
+		t.Error("Expected the connection to be opened with the app token got: ", open.URL.String())
+	}
+}
+
+func TestSocketModeReconnectsOnDisconnect(t *testing.T) {
+	server, _ := fakeSocketMode([][]string{
+		{`{"type": "hello"}`, `{"type": "disconnect", "reason": "refresh_requested"}`},
+		{`{"type": "hello"}`, `{"envelope_id": "e2", "type": "events_api", "payload": {"type": "event_callback", "event": {"type": "app_mention", "channel": "C1", "user": "U1", "text": "<@UCARLOS> list drafts"}}}`},
+	})
+	defer server.Close()
+
+	robot, slack, _ := socketModeRobot(server)
+	if err := slack.connectSocket(); err != nil {
+		t.Fatal(err)
+	}
+	robot.Listen()
+
+	select {
+	case msg := <-robot.ListenChan:
+		if msg.Type != "message" || msg.Text != "<@UCARLOS> list drafts" {
+			t.Error("Expected the mention from the new connection got: ", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a new connection to be opened after the disconnect")
+	}
+}
+
+func TestSocketConnectNeedsAppToken(t *testing.T) {
+	slack := &SlackTransport{Client: &MockHTTPClient{}}
+	if err := slack.connectSocket(); err != ErrNoAppToken {
+		t.Error("Expected ErrNoAppToken got: ", err)
+	}
+}
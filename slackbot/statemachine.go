@@ -0,0 +1,140 @@
+package slackbot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	StageInitial       = "initial"
+	StageGetQuestion   = "getQuestion"
+	StageGetAnswers    = "getAnswers"
+	StageGetScale      = "getScale"
+	StageGetRecipients = "getRecipients"
+	StageGetCloseTime  = "getCloseTime"
+	StageSendPoll      = "sendPoll"
+
+	// Survey questions wait here once they are filled in until the whole survey is sent
+	StageSurveyQuestion = "surveyQuestion"
+
+	StageActive    = "active"
+	StageClosed    = "closed"
+	StageCancelled = "cancelled"
+	StageArchived  = "archived"
+
+	// systemActor is recorded for transitions Carlos makes on its own such as closing a poll at its deadline
+	systemActor = "carlos"
+)
+
+var (
+	ErrIllegalTransition = errors.New("CarlosTheCurious: Poll can not move to that stage from its current stage")
+
+	// pollLifecycle is every move a poll is allowed to make between stages. Scheduled copies of a poll go
+	// straight from initial to active since everything was already filled in on the original, copies made
+	// from a template jump to sendPoll so the creator can check the preview
+	pollLifecycle = newPollLifecycle()
+
+	// creationOrder is the order the creator fills in each part of a poll. Scale and answers are the same
+	// step since a poll only ever has one of them
+	creationOrder = map[string]int{
+		StageGetQuestion:   1,
+		StageGetAnswers:    2,
+		StageGetScale:      2,
+		StageGetRecipients: 3,
+		StageGetCloseTime:  4,
+		StageSendPoll:      5,
+	}
+)
+
+func newPollLifecycle() *StateMachine {
+	machine := NewStateMachine(map[string][]string{
+		StageInitial:        {StageGetQuestion, StageSendPoll, StageActive, StageCancelled},
+		StageGetQuestion:    {StageGetAnswers, StageGetScale, StageGetRecipients, StageSurveyQuestion, StageCancelled},
+		StageGetAnswers:     {StageGetRecipients, StageSurveyQuestion, StageCancelled},
+		StageGetScale:       {StageGetRecipients, StageSurveyQuestion, StageCancelled},
+		StageSurveyQuestion: {StageActive, StageCancelled},
+		StageGetRecipients:  {StageGetCloseTime, StageCancelled},
+		StageGetCloseTime:   {StageSendPoll, StageCancelled},
+		StageSendPoll:       {StageActive, StageCancelled},
+		StageActive:         {StageClosed, StageCancelled, StageArchived},
+		StageClosed:         {StageArchived},
+		StageCancelled:      {},
+		StageArchived:       {},
+	})
+
+	// Until a poll is sent the creator can go back to change any part of it. Returning to where they were is
+	// left to ReturnFromEdit so a draft can never skip ahead past a step it hasn't filled in
+	for from := range creationOrder {
+		for to := range creationOrder {
+			if creationOrder[to] < creationOrder[from] {
+				machine.Allow(from, to)
+			}
+		}
+	}
+	return machine
+}
+
+// StateMachine holds the legal transitions out of each stage
+type StateMachine struct {
+	transitions map[string]map[string]bool
+}
+
+func NewStateMachine(transitions map[string][]string) *StateMachine {
+	machine := &StateMachine{transitions: make(map[string]map[string]bool)}
+	for from, stages := range transitions {
+		machine.Allow(from, stages...)
+	}
+	return machine
+}
+
+func (machine *StateMachine) Allow(from string, to ...string) {
+	if machine.transitions[from] == nil {
+		machine.transitions[from] = make(map[string]bool)
+	}
+	for _, stage := range to {
+		machine.transitions[from][stage] = true
+	}
+}
+
+func (machine *StateMachine) CanTransition(from, to string) bool {
+	return machine.transitions[from][to]
+}
+
+// PollTransition is the audit log of every stage change a poll has gone through and who caused it
+type PollTransition struct {
+	gorm.Model
+	PollID    uint   `gorm:"not null;index"`
+	FromStage string `gorm:"not null"`
+	ToStage   string `gorm:"not null"`
+	Actor     string `gorm:"not null"`
+}
+
+// Transitions returns the stage changes for the poll oldest first
+func (poll *Poll) Transitions() ([]PollTransition, error) {
+	transitions := []PollTransition{}
+	err := GetDB().Where("poll_id = ?", poll.ID).Order("created_at, id").Find(&transitions).Error
+	return transitions, err
+}
+
+// sentAt is when the poll first went active, polls from before transitions were recorded fall back to when they
+// were created
+func (poll *Poll) sentAt() (time.Time, error) {
+	transitions := []PollTransition{}
+	err := GetDB().Where("poll_id = ? AND to_stage = ?", poll.ID, StageActive).Order("created_at, id").Limit(1).Find(&transitions).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(transitions) == 0 {
+		return poll.CreatedAt, nil
+	}
+	return transitions[0].CreatedAt, nil
+}
+
+// FindTransitionsBetween is every stage change across all polls in the time range for auditing
+func FindTransitionsBetween(from, to time.Time) ([]PollTransition, error) {
+	transitions := []PollTransition{}
+	err := GetDB().Where("created_at >= ? AND created_at < ?", from, to).Order("created_at, id").Find(&transitions).Error
+	return transitions, err
+}
@@ -0,0 +1,258 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dklassen/CarlosTheCurious/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// Surveys are put together in the draft stage and can't be changed once they are sent
+	SurveyDraft = "draft"
+	SurveySent  = "sent"
+)
+
+var (
+	ErrEmptySurvey       = errors.New("CarlosTheCurious: Survey has no finished questions to send")
+	ErrSurveyAlreadySent = errors.New("CarlosTheCurious: Survey has already been sent")
+)
+
+// Survey is an ordered list of questions which are sent together. Each question is a poll of its own so it
+// can be of any kind, Carlos walks each recipient through them one at a time
+type Survey struct {
+	gorm.Model
+	UUID    string `gorm:"not null;unique_index"`
+	Title   string `gorm:"not null"`
+	Creator string `gorm:"not null"`
+	Channel string `gorm:"not null"`
+	Stage   string `gorm:"not null"`
+}
+
+// SurveyProgress remembers which question a recipient is on so they can pick the survey up again later
+type SurveyProgress struct {
+	gorm.Model
+	SurveyID uint   `gorm:"not null;index"`
+	SlackID  string `gorm:"not null"`
+
+	// Position of the question the recipient has to answer next
+	Position    int
+	CompletedAt *time.Time
+}
+
+func NewSurvey(title, creator, channel string) *Survey {
+	return &Survey{
+		UUID:    uuid.GenerateUUID(),
+		Title:   title,
+		Creator: creator,
+		Channel: channel,
+		Stage:   SurveyDraft,
+	}
+}
+
+func (survey *Survey) Save() error {
+	return GetDB().Save(survey).Error
+}
+
+func FindSurveyByUUID(uuid string) (*Survey, error) {
+	survey := &Survey{}
+	GetDB().Where("uuid = ?", uuid).First(survey)
+
+	if survey.ID == 0 {
+		return survey, fmt.Errorf("No survey with %s found", uuid)
+	}
+	return survey, nil
+}
+
+func FindSurveyByID(id uint) (*Survey, error) {
+	survey := &Survey{}
+	GetDB().Where("id = ?", id).First(survey)
+
+	if survey.ID == 0 {
+		return survey, fmt.Errorf("No survey with id %d found", id)
+	}
+	return survey, nil
+}
+
+// Questions returns the polls making up the survey in the order they are asked. Questions which were
+// cancelled while being put together are left out
+func (survey *Survey) Questions() ([]Poll, error) {
+	questions := []Poll{}
+	err := GetDB().
+		Where("survey_id = ? AND stage <> ?", survey.ID, StageCancelled).
+		Order("position, id").
+		Find(&questions).Error
+	return questions, err
+}
+
+// AddQuestion starts a new question at the end of the survey. The creator fills it in the same way as a poll
+// in the channel they asked from
+func (survey *Survey) AddQuestion(kind, channel string) (*Poll, error) {
+	if survey.Stage != SurveyDraft {
+		return nil, ErrSurveyAlreadySent
+	}
+
+	var position int
+	GetDB().Model(&Poll{}).Where("survey_id = ?", survey.ID).Count(&position)
+
+	poll := NewPoll(kind, survey.Creator, channel)
+	poll.SurveyID = &survey.ID
+	poll.Position = position
+	return poll, poll.Save()
+}
+
+// Send makes every question live for the recipients and starts each of them off on the first question
+func (survey *Survey) Send(recipients []Recipient, actor string) ([]SurveyProgress, error) {
+	if survey.Stage != SurveyDraft {
+		return nil, ErrSurveyAlreadySent
+	}
+
+	questions, err := survey.Questions()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(questions) == 0 {
+		return nil, ErrEmptySurvey
+	}
+
+	for _, question := range questions {
+		if question.Stage != StageSurveyQuestion {
+			return nil, fmt.Errorf("Question %d of the survey is still being put together", question.Position+1)
+		}
+	}
+
+	// Everything goes out together or not at all. A survey left half sent could never be sent again
+	tx := GetDB().Begin()
+	progress, err := survey.send(tx, questions, recipients, actor)
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		survey.Stage = SurveyDraft
+		return nil, err
+	}
+	return progress, nil
+}
+
+func (survey *Survey) send(tx *gorm.DB, questions []Poll, recipients []Recipient, actor string) ([]SurveyProgress, error) {
+	for i := range questions {
+		// Close up any gaps left by cancelled questions so positions line up with the order they are asked
+		questions[i].Position = i
+
+		// Every question needs its own recipient rows
+		copies := []Recipient{}
+		for _, recipient := range recipients {
+			copies = append(copies, Recipient{SlackID: recipient.SlackID, SlackName: recipient.SlackName})
+		}
+
+		if err := questions[i].setRecipients(tx, copies); err != nil {
+			return nil, err
+		}
+
+		if err := questions[i].transitionWithin(tx, StageActive, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	progress := []SurveyProgress{}
+	for _, recipient := range recipients {
+		p := SurveyProgress{SurveyID: survey.ID, SlackID: recipient.SlackID}
+		if err := tx.Create(&p).Error; err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+
+	survey.Stage = SurveySent
+	return progress, tx.Save(survey).Error
+}
+
+// FindCurrentSurveyProgress is the oldest survey the user still has questions to answer for
+func FindCurrentSurveyProgress(slackID string) *SurveyProgress {
+	progress := &SurveyProgress{}
+	GetDB().Where("slack_id = ? AND completed_at IS NULL", slackID).Order("created_at, id").First(progress)
+	return progress
+}
+
+func FindSurveyProgress(surveyID uint, slackID string) *SurveyProgress {
+	progress := &SurveyProgress{}
+	GetDB().Where("survey_id = ? AND slack_id = ?", surveyID, slackID).First(progress)
+	return progress
+}
+
+// questionFor is the question the recipient has to answer next along with how many questions there are.
+// The question is nil once they have answered everything
+func (survey *Survey) questionFor(progress *SurveyProgress) (*Poll, int, error) {
+	questions, err := survey.Questions()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if progress.Position >= len(questions) {
+		return nil, len(questions), nil
+	}
+	return &questions[progress.Position], len(questions), nil
+}
+
+// Advance moves the recipient on to the next question and marks the survey completed after the last one
+func (progress *SurveyProgress) Advance(total int, now time.Time) error {
+	progress.Position++
+	if progress.Position >= total {
+		progress.CompletedAt = &now
+	}
+	return GetDB().Save(progress).Error
+}
+
+func (survey *Survey) numberCompleted() int {
+	var completed int
+	GetDB().Model(&SurveyProgress{}).Where("survey_id = ? AND completed_at IS NOT NULL", survey.ID).Count(&completed)
+	return completed
+}
+
+func (survey *Survey) numberOfRecipients() int {
+	var recipients int
+	GetDB().Model(&SurveyProgress{}).Where("survey_id = ?", survey.ID).Count(&recipients)
+	return recipients
+}
+
+// SlackQuestionAttachment is a survey question as sent to a recipient
+func (survey *Survey) SlackQuestionAttachment(question *Poll, total int) Attachment {
+	attachment := question.SlackRecipientAttachment()
+	attachment.Pretext = fmt.Sprintf("%s - question %d of %d", survey.Title, question.Position+1, total)
+	return attachment
+}
+
+// SlackSurveySummary is the combined results of every question in the survey
+func (survey *Survey) SlackSurveySummary() ([]Attachment, error) {
+	questions, err := survey.Questions()
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []Attachment{
+		{
+			Title: fmt.Sprintf("%s Results - %s", survey.Title, survey.UUID),
+			Fields: []AttachmentField{
+				{
+					Title: "Completed:",
+					Value: fmt.Sprintf("%d%% - %d out of %d", percentOf(survey.numberCompleted(), survey.numberOfRecipients()), survey.numberCompleted(), survey.numberOfRecipients()),
+					Short: false,
+				},
+			},
+		},
+	}
+
+	for i := range questions {
+		summary := questions[i].SlackPollSummary()
+		summary.Title = fmt.Sprintf("%d. %s", i+1, strings.Title(questions[i].Kind))
+		attachments = append(attachments, summary)
+	}
+	return attachments, nil
+}
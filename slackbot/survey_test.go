@@ -0,0 +1,198 @@
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dklassen/CarlosTheCurious/uuid"
+)
+
+func TestSurveyWalksRecipientThroughQuestions(t *testing.T) {
+	robot := CleanSetup()
+
+	generated := 0
+	uuid.GenerateUUID = func() string {
+		generated++
+		return fmt.Sprintf("id-%d", generated)
+	}
+
+	memory := robot.Transport.(*MemoryTransport)
+
+	creator := func(text string) {
+		robot.Dispatch(&Message{User: "boss", Channel: "DBOSS", Text: text})
+	}
+
+	// `create survey ... poll` also looks like a poll with options but should always make a survey
+	creator("create survey team poll")
+	survey, err := FindSurveyByUUID("id-1")
+	if err != nil {
+		t.Fatal("Expected survey to be created got: ", memory.SentTexts())
+	}
+
+	if survey.Title != "team poll" || survey.Stage != SurveyDraft {
+		t.Fatal("Unexpected survey", survey.Title, survey.Stage)
+	}
+
+	creator("send survey id-1 to <@U1>")
+	if last := memory.LastText(); !strings.Contains(last, "has no questions yet") {
+		t.Error("Expected an empty survey to be refused got: ", last)
+	}
+
+	creator("add scale question to survey id-1")
+	creator("How was the quarter?")
+	creator("1-5 awful, amazing")
+	creator("add feedback question to survey id-1")
+	creator("Anything else?")
+
+	questions, err := survey.Questions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(questions) != 2 || questions[0].Kind != ScalePoll || questions[1].Question != "Anything else?" {
+		t.Fatal("Expected the survey to have two questions in order got: ", questions)
+	}
+
+	for _, question := range questions {
+		if question.Stage != StageSurveyQuestion {
+			t.Fatal("Expected question to be ready to send but it is in stage", question.Stage)
+		}
+	}
+
+	creator("send survey id-1 to <@U1>, <@U2>")
+	posts := memory.Posts()
+	if len(posts) != 2 {
+		t.Fatal("Expected the first question to be sent to each recipient got posts: ", len(posts))
+	}
+
+	if attachments := attachmentsText(posts[0].Attachments); !strings.Contains(attachments, "team poll - question 1 of 2") {
+		t.Error("Expected the first question to be sent got: ", attachments)
+	}
+
+	// U1 answers the first question in the direct message and picks the survey up again later
+	memory.Reset()
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "4"})
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "resume survey"})
+	if posts = memory.Posts(); len(posts) != 2 {
+		t.Fatal("Expected the second question to be sent after answering and again when resuming got posts: ", len(posts))
+	}
+
+	if attachments := attachmentsText(posts[1].Attachments); !strings.Contains(attachments, "team poll - question 2 of 2") {
+		t.Error("Expected to resume on the second question got: ", attachments)
+	}
+
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "Great quarter"})
+	if last := memory.LastText(); last != "That's everything for team poll, thanks for filling it in!" {
+		t.Error("Expected the recipient to be thanked got: ", last)
+	}
+
+	progress := FindSurveyProgress(survey.ID, "U1")
+	if progress.CompletedAt == nil || progress.Position != 2 {
+		t.Error("Expected U1 to have completed the survey got position", progress.Position)
+	}
+
+	if progress := FindSurveyProgress(survey.ID, "U2"); progress.CompletedAt != nil || progress.Position != 0 {
+		t.Error("Expected U2 to still be on the first question got position", progress.Position)
+	}
+
+	attachments, err := survey.SlackSurveySummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attachments) != 3 || attachments[0].Fields[0].Value != "50% - 1 out of 2" {
+		t.Fatal("Unexpected survey summary", attachments)
+	}
+
+	if !strings.Contains(attachments[2].Fields[0].Value, "Great quarter") {
+		t.Error("Expected the feedback answer in the results got: ", attachments[2].Fields[0].Value)
+	}
+}
+
+func TestSurveySendIsAllOrNothing(t *testing.T) {
+	CleanSetup()
+
+	survey := NewSurvey("team", "boss", "DBOSS")
+	if err := survey.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, question := range []string{"How was the quarter?", "Anything else?"} {
+		poll, err := survey.AddQuestion(FeedbackPoll, "DBOSS")
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Question = question
+		poll.Stage = StageSurveyQuestion
+		poll.Save()
+	}
+
+	// Losing the progress table makes the send fail after every question has been made live
+	GetDB().DropTable(&SurveyProgress{})
+	_, err := survey.Send([]Recipient{{SlackID: "U1"}}, "boss")
+	Migrate()
+	if err == nil {
+		t.Fatal("Expected the send to fail")
+	}
+
+	questions, _ := survey.Questions()
+	for _, question := range questions {
+		if question.Stage != StageSurveyQuestion || question.numberOfRecipients() != 0 {
+			t.Fatal("Expected the question to be left as it was got: ", question.Stage, question.numberOfRecipients())
+		}
+	}
+
+	if survey.Stage != SurveyDraft {
+		t.Fatal("Expected the survey to still be a draft got: ", survey.Stage)
+	}
+
+	if _, err := survey.Send([]Recipient{{SlackID: "U1"}}, "boss"); err != nil {
+		t.Fatal("Expected the survey to send on the second try got: ", err)
+	}
+}
+
+func TestSentSurveyQuestionsCannotBeCancelled(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	survey := NewSurvey("team", "boss", "DBOSS")
+	if err := survey.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, question := range []string{"How was the quarter?", "What went well?", "Anything else?"} {
+		poll, err := survey.AddQuestion(FeedbackPoll, "DBOSS")
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Question = question
+		poll.Stage = StageSurveyQuestion
+		poll.Save()
+	}
+
+	if _, err := survey.Send([]Recipient{{SlackID: "U1"}}, "boss"); err != nil {
+		t.Fatal(err)
+	}
+
+	questions, _ := survey.Questions()
+	middle := questions[1]
+
+	// U1 answers the first question and is shown the middle one
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "Fine"})
+
+	memory.Reset()
+	robot.Dispatch(&Message{User: "boss", Channel: "DBOSS", Text: "cancel poll " + middle.UUID})
+	if !strings.Contains(memory.SentText(), "can't be cancelled once the survey is out") {
+		t.Error("Expected cancelling a sent question to be refused got: ", memory.SentText())
+	}
+
+	if questions, _ := survey.Questions(); len(questions) != 3 {
+		t.Fatal("Expected the survey to keep all of its questions got: ", len(questions))
+	}
+
+	robot.Dispatch(&Message{User: "U1", Channel: "DU1", Text: "Shipping"})
+	if progress := FindSurveyProgress(survey.ID, "U1"); progress.Position != 2 {
+		t.Error("Expected U1's answer to the middle question to move them on got position", progress.Position)
+	}
+}
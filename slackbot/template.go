@@ -0,0 +1,76 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ErrTemplateFromDraft = errors.New("CarlosTheCurious: Templates can only be made from polls which have been sent")
+
+// PollTemplate is a name for a poll the creator wants to send again. Starting a poll from the template copies
+// the question, answers and recipients of the poll so only the preview is left to confirm. Only polls which
+// have been sent can be templates, a draft could still be edited and change the template along with it
+type PollTemplate struct {
+	gorm.Model
+	Name    string `gorm:"not null"`
+	Creator string `gorm:"not null;index"`
+	PollID  uint   `gorm:"not null"`
+}
+
+// SaveTemplate names the poll as a template for the creator. Saving another poll under the same name replaces it
+func SaveTemplate(poll *Poll, name string) (*PollTemplate, error) {
+	if !poll.isPublished() {
+		return nil, ErrTemplateFromDraft
+	}
+
+	template := FindTemplate(poll.Creator, name)
+	template.Name = name
+	template.Creator = poll.Creator
+	template.PollID = poll.ID
+	return template, GetDB().Save(template).Error
+}
+
+func FindTemplate(creator, name string) *PollTemplate {
+	template := &PollTemplate{}
+	GetDB().Where("creator = ? AND name = ?", creator, name).First(template)
+	return template
+}
+
+func FindTemplatesByCreator(creator string) ([]PollTemplate, error) {
+	templates := []PollTemplate{}
+	err := GetDB().Where("creator = ?", creator).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// Poll is the poll the template copies from
+func (template *PollTemplate) Poll() (*Poll, error) {
+	poll := &Poll{}
+	GetDB().Where("id = ?", template.PollID).First(poll)
+	if poll.ID == 0 {
+		return nil, fmt.Errorf("Unable to find poll %d for template %s", template.PollID, template.Name)
+	}
+	return poll, nil
+}
+
+// NewPoll copies the template's poll into a new poll for the creator to send from the channel
+func (template *PollTemplate) NewPoll(channel string) (*Poll, error) {
+	source, err := template.Poll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates saved before they had to come from sent polls may still point at a draft
+	if source.isBeingCreated() {
+		return nil, ErrTemplateFromDraft
+	}
+
+	poll, err := source.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	poll.Channel = channel
+	return poll, poll.Save()
+}
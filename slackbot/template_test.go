@@ -0,0 +1,98 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreatePollFromTemplate(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	source := &Poll{
+		Kind:            ResponsePoll,
+		UUID:            "weekly",
+		Creator:         "boss",
+		Channel:         "DOLD",
+		Stage:           "closed",
+		Question:        "Did you ship anything this week?",
+		PossibleAnswers: []PossibleAnswer{{Value: "yes"}, {Value: "no"}},
+		Recipients:      []Recipient{{SlackID: "U1"}, {SlackID: "U2"}},
+	}
+	if err := source.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	command := func(user, text string) string {
+		memory.Reset()
+		robot.Dispatch(&Message{User: user, Channel: "DBOSS", Text: text})
+		return strings.Join(memory.SentTexts(), "|")
+	}
+
+	if output := command("someone_else", "save poll weekly as template standup"); output != "Sorry, only the person who created the poll can save it" {
+		t.Error("Expected only the creator to be able to save a template got: ", output)
+	}
+
+	command("boss", "save poll weekly as template weekly poll")
+	if template := FindTemplate("boss", "weekly poll"); template.PollID != source.ID {
+		t.Fatal("Expected template to be saved for poll", source.ID, "got", template.PollID)
+	}
+
+	// `create poll from template weekly poll` also looks like creating a poll with options
+	output := command("boss", "create poll from template weekly poll")
+	if !strings.HasPrefix(output, "Creating a response poll from template weekly poll") {
+		t.Fatal("Unexpected response creating poll from template: ", output)
+	}
+
+	poll, err := FindFirstInactivePollByMessage(&Message{User: "boss", Channel: "DBOSS"})
+	if err != nil {
+		t.Fatal("Expected a new poll waiting to be sent")
+	}
+
+	if poll.Stage != StageSendPoll || poll.Question != source.Question || poll.UUID == source.UUID {
+		t.Error("Expected a filled in copy at the preview got stage", poll.Stage, "question", poll.Question)
+	}
+
+	if answers, _ := poll.GetAnswers(); len(answers) != 2 {
+		t.Error("Expected the answers to be copied got: ", answers)
+	}
+
+	if recipients, _ := poll.GetRecipients(); len(recipients) != 2 {
+		t.Error("Expected the recipients to be copied got: ", recipients)
+	}
+
+	if posts := memory.Posts(); len(posts) != 1 || !strings.Contains(attachmentsText(posts[0].Attachments), "Did you ship anything this week?") {
+		t.Error("Expected the preview to be posted")
+	}
+
+	if output := command("boss", "create poll from template nope"); output != "Sorry about this but didn't not find a template nope" {
+		t.Error("Unexpected response for a missing template: ", output)
+	}
+
+	if output := command("boss", "save poll "+poll.UUID+" as template copy"); output != "Only active or closed polls can be saved as a template" {
+		t.Error("Expected a draft to be turned away as a template got: ", output)
+	}
+}
+
+func TestTemplateSavedFromADraftIsNotUsed(t *testing.T) {
+	robot := CleanSetup()
+	memory := robot.Transport.(*MemoryTransport)
+
+	draft := &Poll{Kind: FeedbackPoll, UUID: "draft", Creator: "boss", Channel: "DBOSS", Stage: StageSendPoll, Question: "Lunch?"}
+	if err := draft.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SaveTemplate(draft, "lunch"); err != ErrTemplateFromDraft {
+		t.Error("Expected a draft not to be saved as a template got: ", err)
+	}
+
+	// An old template pointing at a draft
+	GetDB().Create(&PollTemplate{Name: "lunch", Creator: "boss", PollID: draft.ID})
+
+	robot.Dispatch(&Message{User: "boss", Channel: "DBOSS", Text: "create poll from template lunch"})
+	expected := "Template lunch was saved from a poll which hasn't been sent yet. Save it again once the poll is active"
+	if memory.SentText() != expected {
+		t.Error("Expected: ", expected, " got: ", memory.SentText())
+	}
+}
@@ -0,0 +1,60 @@
+package slackbot
+
+import "fmt"
+
+// ErrNotAuthorized is the chat platform turning down Carlos's credentials. A revoked or mistyped token won't
+// start working by trying again so this is the one connection failure Carlos gives up on
+type ErrNotAuthorized struct {
+	Reason string
+}
+
+func (err *ErrNotAuthorized) Error() string {
+	return fmt.Sprintf("CarlosTheCurious: Credentials were turned down: %s", err.Reason)
+}
+
+// slackAuthErrors are the error codes Slack answers with when the token is no good
+var slackAuthErrors = map[string]bool{
+	"invalid_auth":     true,
+	"not_authed":       true,
+	"account_inactive": true,
+	"token_revoked":    true,
+	"token_expired":    true,
+}
+
+// slackError is the error for a call Slack answered with ok false. Problems with the token come back as
+// ErrNotAuthorized so they can be told apart from Slack having a bad moment
+func slackError(what, code string) error {
+	if slackAuthErrors[code] {
+		return &ErrNotAuthorized{Reason: code}
+	}
+	return fmt.Errorf("%s: %s", what, code)
+}
+
+// Identity is who the robot is signed in as on the chat platform
+type Identity struct {
+	ID   string
+	Name string
+}
+
+// Transport is everything the robot needs from a chat platform. Polls and conversations only ever reach
+// people through it so they work the same whichever platform carries the messages
+type Transport interface {
+	// Connect signs in to the platform
+	Connect() (*Identity, error)
+
+	// Listen starts handing the messages people send to the robot's workers
+	Listen(robot *Robot)
+
+	SendMessage(channel, text string) error
+	ReplyInThread(channel, threadTS, text string) error
+
+	// PostAttachments posts a rich message and returns where it ended up so it can be updated later
+	PostAttachments(channel, text string, attachments []Attachment) (*PostResponse, error)
+	UpdateMessage(channel, ts, text string, attachments []Attachment) error
+
+	// Users, Channels, Groups and UserGroups are who and where polls can be sent to
+	Users() ([]User, error)
+	Channels() ([]Channel, error)
+	Groups() ([]Group, error)
+	UserGroups() ([]UserGroup, error)
+}
@@ -0,0 +1,15 @@
+package slackbot
+
+type UserGroupList struct {
+	Ok         bool        `json:"ok"`
+	UserGroups []UserGroup `json:"usergroups"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// UserGroup is a Slack user group such as @engineering which can be mentioned with <!subteam^ID>
+type UserGroup struct {
+	ID     string   `json:"id"`
+	Handle string   `json:"handle"`
+	Name   string   `json:"name"`
+	Users  []string `json:"users"`
+}
@@ -6,6 +6,10 @@ type User struct {
 	// SlackID is the string identifier for a team member
 	SlackID      string       `json:"id"`
 	SlackProfile SlackProfile `json:"profile"`
+
+	// Deactivated accounts and bots are left out when a poll is sent to a whole channel
+	Deleted bool `json:"deleted"`
+	IsBot   bool `json:"is_bot"`
 }
 
 // Slack collection of users
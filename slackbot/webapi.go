@@ -0,0 +1,170 @@
+package slackbot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// Slack allows roughly one chat.postMessage a second per channel with bursts above that. Spacing the calls
+	// out keeps a poll sent to a big channel under the limit most of the time and 429s take care of the rest
+	webAPICallInterval = 100 * time.Millisecond
+	webAPIMaxAttempts  = 5
+	minRetryDelay      = 500 * time.Millisecond
+	maxRetryDelay      = 30 * time.Second
+)
+
+// QueuedWebClient spaces Web API calls out so they start at least Interval apart. When Slack says we are going
+// too fast the call waits as long as the Retry-After header asks, and calls which Slack can't have acted on are
+// tried again with a backoff. A call waiting to try again doesn't hold up the rest of the queue
+type QueuedWebClient struct {
+	Client      WebClienter
+	Interval    time.Duration
+	MaxAttempts int
+	Backoff     Backoff
+
+	mu    sync.Mutex
+	last  time.Time
+	sleep func(time.Duration)
+}
+
+func NewQueuedWebClient(client WebClienter) *QueuedWebClient {
+	return &QueuedWebClient{
+		Client:      client,
+		Interval:    webAPICallInterval,
+		MaxAttempts: webAPIMaxAttempts,
+		Backoff:     Backoff{Min: minRetryDelay, Max: maxRetryDelay},
+		sleep:       time.Sleep,
+	}
+}
+
+// Do waits for its turn in the queue and keeps trying until the call works, fails for good or runs out of
+// attempts. The last response or error is handed back
+func (queue *QueuedWebClient) Do(req *http.Request) (*http.Response, error) {
+	backoff := queue.Backoff
+	backoff.Reset()
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		queue.wait(queue.takeTurn())
+
+		retry, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return nil, rewindErr
+		}
+
+		resp, err = queue.Client.Do(retry)
+
+		delay, again := retryDelay(resp, err, &backoff)
+		if !again || attempt >= queue.MaxAttempts {
+			return resp, err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"url":      req.URL.Path,
+			"attempt":  attempt,
+			"retry_in": delay,
+		}).Warn("Slack Web API call failed, trying again: ", describeFailure(resp, err))
+
+		if resp != nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		queue.wait(delay)
+	}
+}
+
+// takeTurn books the next free slot in the queue and returns how long until it comes round. The lock is only
+// held while booking so nobody waits on another call's sleep
+func (queue *QueuedWebClient) takeTurn() time.Duration {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	next := queue.last.Add(queue.Interval)
+	if next.Before(now) {
+		next = now
+	}
+	queue.last = next
+	return next.Sub(now)
+}
+
+func (queue *QueuedWebClient) wait(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+
+	sleep := queue.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	sleep(delay)
+}
+
+// rewind gives back a request which can be sent again. Requests with a body need a fresh copy of it each time
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	retry := *req
+	retry.Body = body
+	return &retry, nil
+}
+
+// retryDelay decides whether a call is worth trying again and how long to wait first. Only calls Slack can't
+// have acted on are tried again, sending a chat.postMessage which did go through a second time would DM
+// someone twice. Rate limits say how long to wait themselves, the rest back off
+func retryDelay(resp *http.Response, err error, backoff *Backoff) (time.Duration, bool) {
+	if err != nil {
+		return backoff.Next(), neverSent(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		return backoff.Next(), true
+	case http.StatusServiceUnavailable:
+		return backoff.Next(), true
+	}
+	return 0, false
+}
+
+// neverSent is true for errors from before the request left, like failing to look up or connect to Slack. A
+// timeout or dropped connection after that leaves us not knowing whether Slack got the request
+func neverSent(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	switch err := err.(type) {
+	case *net.DNSError:
+		return true
+	case *net.OpError:
+		return err.Op == "dial"
+	}
+	return false
+}
+
+func describeFailure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
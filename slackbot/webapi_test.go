@@ -0,0 +1,204 @@
+package slackbot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// scriptedResponse is one answer from a ScriptedHTTPClient, a failed call when Err is set
+type scriptedResponse struct {
+	Status     int
+	RetryAfter string
+	Err        error
+}
+
+// ScriptedHTTPClient answers each call with the next response in the script and remembers the bodies it was sent
+type ScriptedHTTPClient struct {
+	Script []scriptedResponse
+	Bodies []string
+}
+
+func (client *ScriptedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		body = string(data)
+	}
+	client.Bodies = append(client.Bodies, body)
+
+	next := client.Script[0]
+	client.Script = client.Script[1:]
+	if next.Err != nil {
+		return nil, next.Err
+	}
+
+	resp := &http.Response{
+		StatusCode: next.Status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"ok": true}`))),
+	}
+	if next.RetryAfter != "" {
+		resp.Header.Set("Retry-After", next.RetryAfter)
+	}
+	return resp, nil
+}
+
+var dialError = &url.Error{Op: "Post", URL: "https://slack.com/api/chat.postMessage", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+
+func TestQueuedWebClientRetries(t *testing.T) {
+	var testCases = []struct {
+		Name             string
+		Script           []scriptedResponse
+		ExpectedStatus   int
+		ExpectedError    bool
+		ExpectedAttempts int
+		ExpectedWaits    []time.Duration
+	}{
+		{
+			Name:             "works first time",
+			Script:           []scriptedResponse{{Status: 200}},
+			ExpectedStatus:   200,
+			ExpectedAttempts: 1,
+			ExpectedWaits:    []time.Duration{},
+		},
+		{
+			Name:             "waits as long as Retry-After asks",
+			Script:           []scriptedResponse{{Status: 429, RetryAfter: "3"}, {Status: 200}},
+			ExpectedStatus:   200,
+			ExpectedAttempts: 2,
+			ExpectedWaits:    []time.Duration{3 * time.Second},
+		},
+		{
+			Name:             "backs off when the call never reached Slack",
+			Script:           []scriptedResponse{{Err: dialError}, {Status: 503}, {Status: 429}, {Status: 200}},
+			ExpectedStatus:   200,
+			ExpectedAttempts: 4,
+			ExpectedWaits:    []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second},
+		},
+		{
+			Name:             "gives up when Slack may have acted on the call",
+			Script:           []scriptedResponse{{Err: errors.New("connection reset")}, {Status: 200}},
+			ExpectedError:    true,
+			ExpectedAttempts: 1,
+			ExpectedWaits:    []time.Duration{},
+		},
+		{
+			Name:             "gives up when Slack fell over part way through",
+			Script:           []scriptedResponse{{Status: 502}, {Status: 200}},
+			ExpectedStatus:   502,
+			ExpectedAttempts: 1,
+			ExpectedWaits:    []time.Duration{},
+		},
+		{
+			Name:             "gives up on requests which are wrong",
+			Script:           []scriptedResponse{{Status: 400}, {Status: 200}},
+			ExpectedStatus:   400,
+			ExpectedAttempts: 1,
+			ExpectedWaits:    []time.Duration{},
+		},
+		{
+			Name:             "gives up after running out of attempts",
+			Script:           []scriptedResponse{{Status: 503}, {Status: 503}, {Status: 503}, {Status: 503}, {Err: dialError}},
+			ExpectedError:    true,
+			ExpectedAttempts: 5,
+			ExpectedWaits:    []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second},
+		},
+	}
+
+	for _, testCase := range testCases {
+		client := &ScriptedHTTPClient{Script: testCase.Script}
+		waits := []time.Duration{}
+
+		queue := NewQueuedWebClient(client)
+		queue.Interval = 0
+		queue.sleep = func(delay time.Duration) { waits = append(waits, delay) }
+
+		req, _ := http.NewRequest("POST", "https://slack.com/api/chat.postMessage", bytes.NewReader([]byte("text=hi")))
+		resp, err := queue.Do(req)
+
+		if testCase.ExpectedError != (err != nil) {
+			t.Error(testCase.Name, ": unexpected error ", err)
+		} else if err == nil && resp.StatusCode != testCase.ExpectedStatus {
+			t.Error(testCase.Name, ": expected status ", testCase.ExpectedStatus, " got: ", resp.StatusCode)
+		}
+
+		if len(client.Bodies) != testCase.ExpectedAttempts {
+			t.Error(testCase.Name, ": expected ", testCase.ExpectedAttempts, " attempts got: ", len(client.Bodies))
+		}
+
+		for _, body := range client.Bodies {
+			if body != "text=hi" {
+				t.Error(testCase.Name, ": expected every attempt to send the whole body got: ", body)
+			}
+		}
+
+		if len(waits) != len(testCase.ExpectedWaits) {
+			t.Error(testCase.Name, ": expected waits ", testCase.ExpectedWaits, " got: ", waits)
+			continue
+		}
+
+		for i, wait := range waits {
+			if wait != testCase.ExpectedWaits[i] {
+				t.Error(testCase.Name, ": expected waits ", testCase.ExpectedWaits, " got: ", waits)
+			}
+		}
+	}
+}
+
+func TestQueuedWebClientSpacesOutCalls(t *testing.T) {
+	client := &ScriptedHTTPClient{Script: []scriptedResponse{{Status: 200}, {Status: 200}}}
+	waits := []time.Duration{}
+
+	queue := NewQueuedWebClient(client)
+	queue.Interval = time.Minute
+	queue.sleep = func(delay time.Duration) { waits = append(waits, delay) }
+
+	for range []int{1, 2} {
+		req, _ := http.NewRequest("GET", "https://slack.com/api/chat.postMessage", nil)
+		if _, err := queue.Do(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(waits) != 1 || waits[0] <= 59*time.Second {
+		t.Error("Expected the second call to wait its turn got: ", waits)
+	}
+}
+
+func TestQueuedWebClientLetsOthersGoWhileWaiting(t *testing.T) {
+	client := &ScriptedHTTPClient{Script: []scriptedResponse{{Status: 429, RetryAfter: "30"}, {Status: 200}, {Status: 200}}}
+
+	queue := NewQueuedWebClient(client)
+	queue.Interval = 0
+
+	// Another call comes along while the first is waiting out the rate limit. Holding the queue through the
+	// wait would deadlock here
+	var other *http.Response
+	queue.sleep = func(delay time.Duration) {
+		req, _ := http.NewRequest("GET", "https://slack.com/api/chat.update", nil)
+		other, _ = queue.Do(req)
+	}
+
+	req, _ := http.NewRequest("GET", "https://slack.com/api/chat.postMessage", nil)
+	resp, err := queue.Do(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Expected the first call to work in the end got: ", resp, err)
+	}
+
+	if other == nil || other.StatusCode != 200 {
+		t.Error("Expected the other call to go ahead while the first waited got: ", other)
+	}
+}
+
+func TestReadPostResponseReportsRateLimits(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Body: ioutil.NopCloser(bytes.NewReader([]byte("slow down")))}
+	if _, err := readPostResponse(resp); err == nil || err.Error() != "ratelimited" {
+		t.Error("Expected the rate limit to be reported got: ", err)
+	}
+}